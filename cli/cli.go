@@ -12,6 +12,8 @@ type CliArgs struct {
 	RemoveArgs  RemoveArgs
 	SearchArgs  SearchArgs
 	UpgradeArgs UpgradeArgs
+	LogArgs     LogArgs
+	CacheArgs   CacheArgs
 }
 
 func ParseCLI(args []string) CliArgs {
@@ -31,6 +33,21 @@ func ParseCLI(args []string) CliArgs {
 		yes := installCmd.Bool("yes", false, "Skip confirmation prompts")
 		pkgdeps := installCmd.String("pkgdeps", "", "Install packages from file")
 		static := installCmd.Bool("static", false, "Build static binary")
+		track := installCmd.String("track", "", "Upgrade channel: head, branch:<name> or latest-tag[:<filter>]")
+		smoke := installCmd.String("smoke", "", "Arguments to run the built binary with before installing")
+		smokeExpect := installCmd.String("smoke-expect", "", "Regex the smoke test output must match")
+		inPlace := installCmd.Bool("in-place", false, "Build local sources in place instead of a snapshot copy")
+		sha256 := installCmd.String("sha256", "", "Expected SHA-256 digest of an archive source")
+		vcsName := installCmd.String("vcs", "", "Version control system: git, hg or fossil")
+		subdir := installCmd.String("subdir", "", "Build the package in this subdirectory of the repository")
+		pr := installCmd.Int("pr", 0, "Install from a GitHub pull request or GitLab merge request")
+		ref := installCmd.String("ref", "", "Install from an arbitrary ref like refs/pull/1/head")
+		sandboxed := installCmd.Bool("sandbox", false, "Build without network access on a read-only root")
+		quiet := installCmd.Bool("quiet", false, "Only write build output to the build log")
+		jobs := installCmd.Int("jobs", 0, "Parallel build jobs (default: number of CPUs)")
+		installCmd.IntVar(jobs, "j", 0, "Shorthand for --jobs")
+		target := installCmd.String("target", "", "Build for another platform, e.g. aarch64 or aarch64-linux-musl")
+		rebuild := installCmd.Bool("rebuild", false, "Build even if the build cache has a matching binary")
 		
 		installCmd.Parse(args[1:])
 		
@@ -50,12 +67,26 @@ func ParseCLI(args []string) CliArgs {
 		}
 		
 		cliArgs.InstallArgs = InstallArgs{
-			Packages: packages,
-			Branch:   *branch,
-			Patches:  *patches,
-			Yes:      *yes,
-			PkgDeps:  *pkgdeps,
-			Static:   *static,
+			Packages:    packages,
+			Branch:      *branch,
+			Patches:     *patches,
+			Yes:         *yes,
+			PkgDeps:     *pkgdeps,
+			Static:      *static,
+			Track:       *track,
+			Smoke:       *smoke,
+			SmokeExpect: *smokeExpect,
+			InPlace:     *inPlace,
+			SHA256:      *sha256,
+			VCS:         *vcsName,
+			Subdir:      *subdir,
+			PR:          *pr,
+			Ref:         *ref,
+			Sandbox:     *sandboxed,
+			Quiet:       *quiet,
+			Jobs:        *jobs,
+			Target:      *target,
+			Rebuild:     *rebuild,
 		}
 
 	case "list":
@@ -80,9 +111,37 @@ func ParseCLI(args []string) CliArgs {
 		cliArgs.Command = CommandUpgrade
 		upgradeCmd := flag.NewFlagSet("upgrade", flag.ExitOnError)
 		yes := upgradeCmd.Bool("yes", false, "Skip confirmation prompts")
+		report := upgradeCmd.String("report", "", "Write a summary report to file")
+		reportFormat := upgradeCmd.String("report-format", "", "Report format: json or junit (default from file extension)")
+		quiet := upgradeCmd.Bool("quiet", false, "Only write build output to the build logs")
 		upgradeCmd.Parse(args[1:])
 		cliArgs.UpgradeArgs = UpgradeArgs{
-			Yes: *yes,
+			Yes:          *yes,
+			Report:       *report,
+			ReportFormat: *reportFormat,
+			Quiet:        *quiet,
+		}
+	case "log":
+		cliArgs.Command = CommandLog
+		logCmd := flag.NewFlagSet("log", flag.ExitOnError)
+		last := logCmd.Bool("last", false, "Show the most recent build log")
+		failed := logCmd.Bool("failed", false, "Show the most recent failed build log")
+		logCmd.Parse(reorderFlags(args[1:]))
+		cliArgs.LogArgs = LogArgs{
+			Last:   *last,
+			Failed: *failed,
+		}
+		if len(logCmd.Args()) > 0 {
+			cliArgs.LogArgs.Package = logCmd.Args()[0]
+		}
+	case "cache":
+		cliArgs.Command = CommandCache
+		cacheCmd := flag.NewFlagSet("cache", flag.ExitOnError)
+		all := cacheCmd.Bool("all", false, "Remove every cached build")
+		cacheCmd.Parse(reorderFlags(args[1:]))
+		cliArgs.CacheArgs.All = *all
+		if len(cacheCmd.Args()) > 0 {
+			cliArgs.CacheArgs.Action = cacheCmd.Args()[0]
 		}
 	default:
 		if args[0] == "--help" || args[0] == "-h" {
@@ -93,3 +152,17 @@ func ParseCLI(args []string) CliArgs {
 	}
 	return cliArgs
 }
+
+// reorderFlags moves flags ahead of positional arguments so that
+// "argon log foo --last" parses like "argon log --last foo".
+func reorderFlags(args []string) []string {
+	var flags, rest []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return append(flags, rest...)
+}
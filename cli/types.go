@@ -9,16 +9,32 @@ const (
 	CommandSearch
 	CommandHelp
 	CommandUpgrade
+	CommandLog
+	CommandCache
 	CommandUnknown
 )
 
 type InstallArgs struct {
-	Packages []string
-	Branch   string
-	Patches  string
-	Yes      bool
-	PkgDeps  string
-	Static   bool
+	Packages    []string
+	Branch      string
+	Patches     string
+	Yes         bool
+	PkgDeps     string
+	Static      bool
+	Track       string
+	Smoke       string
+	SmokeExpect string
+	InPlace     bool
+	SHA256      string
+	VCS         string
+	Subdir      string
+	PR          int
+	Ref         string
+	Sandbox     bool
+	Quiet       bool
+	Jobs        int
+	Target      string
+	Rebuild     bool
 }
 
 type RemoveArgs struct {
@@ -30,5 +46,19 @@ type SearchArgs struct {
 }
 
 type UpgradeArgs struct {
-	Yes bool
+	Yes          bool
+	Report       string
+	ReportFormat string
+	Quiet        bool
+}
+
+type CacheArgs struct {
+	Action string
+	All    bool
+}
+
+type LogArgs struct {
+	Package string
+	Last    bool
+	Failed  bool
 }
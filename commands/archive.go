@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"argon-go/cli"
+	"argon-go/utils"
+)
+
+func openArchive(archiveURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(archiveURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive URL %q: %w", archiveURL, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return os.Open(u.Path)
+	case "http", "https":
+		req, err := http.NewRequest(http.MethodGet, archiveURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", agent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("download failed: %s", resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return nil, fmt.Errorf("unsupported archive URL scheme %q", u.Scheme)
+}
+
+func fetchArchive(archiveURL, format, wantSum string) (string, string, error) {
+	wantSum = strings.ToLower(strings.TrimPrefix(wantSum, "sha256:"))
+	if err := os.MkdirAll(utils.ArgonArchivesDir, 0755); err != nil {
+		return "", "", err
+	}
+
+	if wantSum != "" {
+		cached := filepath.Join(utils.ArgonArchivesDir, wantSum+"."+format)
+		if sum, err := fileSHA256(cached); err == nil && sum == wantSum {
+			fmt.Printf("Using cached archive %s\n", cached)
+			return cached, sum, nil
+		}
+	}
+
+	fmt.Printf("Fetching %s\n", archiveURL)
+	body, err := openArchive(archiveURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(utils.ArgonArchivesDir, "download-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), body); err != nil {
+		tmp.Close()
+		return "", "", fmt.Errorf("download failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", err
+	}
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if wantSum == "" && !strings.HasPrefix(archiveURL, "file://") {
+		return "", "", fmt.Errorf("refusing unverified download; pass --sha256 %s to pin it", sum)
+	}
+	if wantSum != "" && sum != wantSum {
+		return "", "", fmt.Errorf("checksum mismatch: expected %s, got %s", wantSum, sum)
+	}
+
+	cached := filepath.Join(utils.ArgonArchivesDir, sum+"."+format)
+	if err := os.Rename(tmp.Name(), cached); err != nil {
+		return "", "", err
+	}
+	return cached, sum, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func installArchive(spec string, args *cli.InstallArgs) error {
+	archiveURL := spec
+	if utils.IsLocalPath(spec) {
+		abs, err := filepath.Abs(spec)
+		if err != nil {
+			return err
+		}
+		archiveURL = "file://" + abs
+	}
+	format := utils.ArchiveFormat(archiveURL)
+	repoName := packageName(utils.ArchiveName(archiveURL), args.Subdir)
+
+	fmt.Printf("Installing %s (archive)\n", archiveURL)
+	start := time.Now()
+
+	if err := preflight(repoName, "", args.Target, args.Static); err != nil {
+		return err
+	}
+
+	archivePath, sum, err := fetchArchive(archiveURL, format, args.SHA256)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := os.MkdirTemp(filepath.Join(utils.ArgonTempDir, "builds"), repoName+"-archive-")
+	if err != nil {
+		return fmt.Errorf("failed to create build workspace: %w", err)
+	}
+	// the build user has to reach the source root below it
+	if err := os.Chmod(workspace, 0755); err != nil {
+		os.RemoveAll(workspace)
+		return err
+	}
+	if err := utils.ExtractArchive(archivePath, format, workspace); err != nil {
+		os.RemoveAll(workspace)
+		return fmt.Errorf("failed to extract %s: %w", filepath.Base(archiveURL), err)
+	}
+	buildDir := utils.SourceRoot(workspace)
+
+	patches, err := applyPatches(buildDir, args.Patches)
+	if err != nil {
+		os.RemoveAll(workspace)
+		return fmt.Errorf("failed to apply patches: %w", err)
+	}
+
+	record := utils.Package{
+		Name:   repoName,
+		Repo:   archiveURL,
+		Source: utils.SourceArchive,
+		Digest: "sha256:" + sum,
+		Static: args.Static,
+		Smoke:  smokeTestFromArgs(args),
+		Subdir: args.Subdir,
+	}
+	if record.Smoke == nil {
+		record.Smoke = installedSmokeTest(repoName)
+	}
+
+	if err := buildAndInstall(buildDir, args, record, patches); err != nil {
+		fmt.Printf("Build workspace left at %s\n", workspace)
+		return err
+	}
+	os.RemoveAll(workspace)
+
+	elapsed := time.Since(start)
+	fmt.Printf("Installed in %.2fs\n", elapsed.Seconds())
+	return nil
+}
@@ -0,0 +1,377 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"argon-go/config"
+	"argon-go/pkgconfig"
+	"argon-go/sandbox"
+	"argon-go/utils"
+)
+
+// buildContext carries what every build step needs to know about how to run.
+type buildContext struct {
+	user *utils.BuildUser
+	home string
+	// sandbox is nil unless the build runs isolated
+	sandbox  *config.SandboxPolicy
+	writable []string
+	tmp      string
+	// log receives everything the build prints, out is where commands write
+	log *os.File
+	out io.Writer
+	// jobs is the build parallelism, env is added to every build command
+	jobs   int
+	target utils.Target
+	build  *utils.BuildEnv
+	env    []string
+	// system and requires come from the recipe
+	system   string
+	requires []string
+	cache    compilerCache
+}
+
+// defaultPrefetch fetches dependencies for build systems that would
+// otherwise download them during the build.
+var defaultPrefetch = map[string][]string{
+	"Cargo.toml": {"cargo", "fetch"},
+	"build.zig":  {"zig", "build", "--fetch"},
+}
+
+// resolveBuildUser picks the account builds run as. Building as root takes
+// an explicit "build_user": "root" in the config.
+func resolveBuildUser() (*utils.BuildUser, error) {
+	if os.Geteuid() != 0 {
+		return nil, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	switch cfg.BuildUser {
+	case "root":
+		return nil, nil
+	case "":
+	default:
+		u, err := utils.LookupBuildUser(cfg.BuildUser)
+		if err != nil {
+			return nil, fmt.Errorf("build user %s: %w", cfg.BuildUser, err)
+		}
+		return u, nil
+	}
+
+	candidates := []string{utils.BuildUserName, os.Getenv("SUDO_USER"), os.Getenv("DOAS_USER")}
+	for _, name := range candidates {
+		if name == "" || name == "root" {
+			continue
+		}
+		if u, err := utils.LookupBuildUser(name); err == nil {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("refusing to build as root: create an '%s' user, run argon through sudo/doas or set \"build_user\": \"root\" in %s", utils.BuildUserName, config.ConfigPath)
+}
+
+// newBuildContext always returns a context so the caller can close its log,
+// even when setting up the build failed.
+func newBuildContext(sourceDir string, record utils.Package, quiet bool) (*buildContext, error) {
+	b := &buildContext{out: os.Stdout}
+	if log, err := openBuildLog(record.Name); err != nil {
+		fmt.Printf("Warning: could not open build log: %v\n", err)
+	} else {
+		b.log = log
+		b.out = io.MultiWriter(os.Stdout, log)
+		if quiet {
+			b.out = log
+		}
+		fmt.Fprintf(log, "# %s %s %s\n# built %s in %s\n", record.Name, record.Repo, record.Hash, time.Now().Format(time.RFC3339), sourceDir)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	recipe, err := config.LoadRecipe(record.Name)
+	if err != nil {
+		return b, err
+	}
+
+	b.system, b.requires = recipe.BuildSystem, recipe.Requires
+	b.jobs = buildJobs(record, recipe, cfg)
+	b.env = append(b.env,
+		"MAKEFLAGS="+strings.TrimSpace(os.Getenv("MAKEFLAGS")+" -j"+strconv.Itoa(b.jobs)),
+		"CMAKE_BUILD_PARALLEL_LEVEL="+strconv.Itoa(b.jobs),
+		"CARGO_BUILD_JOBS="+strconv.Itoa(b.jobs),
+	)
+
+	if b.log != nil {
+		fmt.Fprintf(b.log, "# jobs: %d\n", b.jobs)
+	}
+
+	if record.Sandbox || cfg.Sandbox {
+		b.sandbox = &config.SandboxPolicy{}
+		if recipe.Sandbox != nil {
+			b.sandbox = recipe.Sandbox
+		}
+	}
+
+	if os.Geteuid() != 0 {
+		if b.sandbox != nil {
+			return b, fmt.Errorf("sandboxed builds have to be started as root")
+		}
+		return b, nil
+	}
+
+	inPlace := record.InPlace
+	if inPlace {
+		// never hand someone else's checkout to another account
+		owner, err := utils.DirOwner(sourceDir)
+		if err != nil {
+			return b, err
+		}
+		if !owner.IsRoot() {
+			b.user = owner
+		}
+	} else {
+		user, err := resolveBuildUser()
+		if err != nil {
+			return b, err
+		}
+		b.user = user
+	}
+	if b.user == nil || b.user.IsRoot() {
+		if b.sandbox != nil {
+			return b, fmt.Errorf("sandboxed builds need an unprivileged build user")
+		}
+		b.user = nil
+		return b, nil
+	}
+
+	if !inPlace {
+		if err := b.user.Chown(sourceDir); err != nil {
+			return b, fmt.Errorf("failed to hand build directory to %s: %w", b.user.Name, err)
+		}
+	}
+
+	b.home = b.user.Home
+	if !utils.DirectoryExists(b.home) {
+		b.home = filepath.Join(utils.ArgonLibDir, "home", b.user.Name)
+		if err := os.MkdirAll(b.home, 0755); err != nil {
+			return b, err
+		}
+		if err := os.Chown(b.home, int(b.user.Uid), int(b.user.Gid)); err != nil {
+			return b, err
+		}
+	}
+
+	if b.sandbox != nil {
+		b.tmp, err = os.MkdirTemp(filepath.Join(utils.ArgonTempDir, "tmp"), record.Name+"-")
+		if err != nil {
+			return b, err
+		}
+		if err := b.user.Chown(b.tmp); err != nil {
+			os.RemoveAll(b.tmp)
+			return b, err
+		}
+		b.writable = []string{sourceDir, b.home, b.tmp}
+		fmt.Printf("Building as %s in a sandbox\n", b.user.Name)
+		return b, nil
+	}
+	fmt.Printf("Building as %s\n", b.user.Name)
+	return b, nil
+}
+
+// setTarget resolves the target once the build system is known, as only
+// cargo and zig build against the libc of the target, and derives the build
+// environment from it.
+func (b *buildContext) setTarget(record utils.Package, system string) error {
+	cfg, _ := config.Load()
+	recipe, err := config.LoadRecipe(record.Name)
+	if err != nil {
+		return err
+	}
+	b.target, err = utils.ResolveTarget(record.Target, record.Static && buildsForLibc(system))
+	if err != nil {
+		return err
+	}
+
+	if !pkgconfig.CheckPkgConfigExists() {
+		fmt.Println("Warning: pkg-config not found in PATH")
+	}
+	cflags, libs := pkgconfig.GetFlags(record.Name, record.Static)
+	var static *utils.BuildEnv
+	if record.Static {
+		static = &utils.BuildEnv{Env: map[string]string{"CFLAGS": "-static"}}
+		if !strings.Contains(libs, "-static") {
+			static.Env["LDFLAGS"] = "-static"
+		}
+	}
+	// flags accumulate from the caller's environment, pkg-config, the global
+	// config, the recipe and --static, in that order; the cross toolchain
+	// comes before the config so it can be overridden
+	b.build = utils.MergeBuildEnv(
+		utils.FlagsFromEnviron(os.Getenv),
+		&utils.BuildEnv{Env: map[string]string{"CFLAGS": cflags, "LDFLAGS": libs}},
+		&utils.BuildEnv{Env: b.target.CrossEnv()},
+		cfg.Build,
+		recipe.Build,
+		static,
+	)
+	b.env = append(b.env, b.build.Environ()...)
+	b.env = append(b.env, "ARGON_TARGET="+b.target.String())
+
+	if b.log != nil {
+		fmt.Fprintf(b.log, "# target: %s\n", b.target)
+		for _, kv := range b.build.Environ() {
+			fmt.Fprintf(b.log, "# env: %s\n", kv)
+		}
+	}
+	return nil
+}
+
+// buildsForLibc reports whether a build system picks the libc from the
+// target, rather than using whatever the host's C compiler links against.
+func buildsForLibc(system string) bool {
+	return system == "cargo" || system == "zig"
+}
+
+// compilerCacheSetting lets the recipe override the global config. Packages
+// that need a signature never use a cache, whose objects no signature covers.
+func compilerCacheSetting(name string) string {
+	if requiresSignature(name) {
+		return compilerCacheOff
+	}
+	cfg, _ := config.Load()
+	if recipe, err := config.LoadRecipe(name); err == nil && recipe.CompilerCache != "" {
+		return recipe.CompilerCache
+	}
+	return cfg.CompilerCache
+}
+
+// buildJobs picks the parallelism: --jobs, then the recipe, then the global
+// config, then one job per CPU.
+func buildJobs(record utils.Package, recipe config.Recipe, cfg config.Config) int {
+	for _, jobs := range []int{record.Jobs, recipe.Jobs, cfg.Jobs} {
+		if jobs > 0 {
+			return jobs
+		}
+	}
+	return runtime.NumCPU()
+}
+
+func (b *buildContext) jobsFlag() string {
+	return "-j" + strconv.Itoa(b.jobs)
+}
+
+func (b *buildContext) makeArgs() []string {
+	return append([]string{b.jobsFlag()}, b.build.MakeArgs...)
+}
+
+func (b *buildContext) close(buildErr error) {
+	if b.tmp != "" {
+		os.RemoveAll(b.tmp)
+	}
+	if b.log == nil {
+		return
+	}
+	if buildErr != nil {
+		fmt.Fprintf(b.log, "# failed: %v\n", buildErr)
+	}
+	path := finishBuildLog(b.log, buildErr != nil)
+	if buildErr != nil {
+		fmt.Printf("Build log: %s\n", path)
+	}
+}
+
+// printf reports a build step on the terminal and in the log.
+func (b *buildContext) printf(format string, args ...any) {
+	fmt.Printf(format, args...)
+	if b.log != nil {
+		fmt.Fprintf(b.log, format, args...)
+	}
+}
+
+// prefetch downloads dependencies while the network is still reachable.
+func (b *buildContext) prefetch(buildDir, buildFile string) error {
+	if b.sandbox == nil || b.sandbox.Network {
+		return nil
+	}
+	argv := b.sandbox.Prefetch
+	if len(argv) == 0 {
+		argv = defaultPrefetch[buildFile]
+	}
+	if len(argv) == 0 {
+		return nil
+	}
+	b.printf("Prefetching: %s\n", strings.Join(argv, " "))
+	if err := b.fetchCommand(buildDir, argv[0], argv[1:]...).Run(); err != nil {
+		return fmt.Errorf("prefetch failed: %w", err)
+	}
+	return nil
+}
+
+// own gives a path created by argon itself during the build to the build user.
+func (b *buildContext) own(path string) error {
+	if b.user == nil {
+		return nil
+	}
+	return b.user.Chown(path)
+}
+
+// apply drops cmd to the build user.
+func (b *buildContext) apply(cmd *exec.Cmd) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if b.user == nil {
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: b.user.Uid, Gid: b.user.Gid, Groups: []uint32{}},
+	}
+	cmd.Env = append(cmd.Env,
+		"HOME="+b.home,
+		"USER="+b.user.Name,
+		"LOGNAME="+b.user.Name,
+		// the worktree's git metadata lives in root's source cache
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=safe.directory",
+		"GIT_CONFIG_VALUE_0=*",
+	)
+	if b.tmp != "" {
+		cmd.Env = append(cmd.Env, "TMPDIR="+b.tmp)
+	}
+}
+
+// command runs a build step, cut off from the network when sandboxed.
+func (b *buildContext) command(dir, name string, args ...string) *exec.Cmd {
+	return b.sandboxed(dir, false, name, args...)
+}
+
+// fetchCommand runs a step that may download, like rustup or a prefetch.
+func (b *buildContext) fetchCommand(dir, name string, args ...string) *exec.Cmd {
+	return b.sandboxed(dir, true, name, args...)
+}
+
+func (b *buildContext) sandboxed(dir string, network bool, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = b.out
+	cmd.Stderr = b.out
+	b.apply(cmd)
+	cmd.Env = append(cmd.Env, b.env...)
+	if b.sandbox == nil {
+		return cmd
+	}
+	return sandbox.Wrap(cmd, b.user.Uid, b.user.Gid, b.writable, network || b.sandbox.Network)
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"argon-go/config"
+	"argon-go/utils"
+)
+
+const (
+	defaultKeepLogs = 10
+	logTimeFormat   = "20060102-150405.000"
+	failedLogSuffix = "-failed.log"
+)
+
+func logDir(name string) string {
+	return filepath.Join(utils.ArgonLogsDir, name)
+}
+
+func openBuildLog(name string) (*os.File, error) {
+	dir := logDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, time.Now().Format(logTimeFormat)+".log")
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+}
+
+// finishBuildLog closes the log, marks it if the build failed and drops the
+// oldest logs beyond the configured limit. It returns the final path.
+func finishBuildLog(log *os.File, failed bool) string {
+	path := log.Name()
+	log.Close()
+	if failed {
+		failedPath := strings.TrimSuffix(path, ".log") + failedLogSuffix
+		if err := os.Rename(path, failedPath); err == nil {
+			path = failedPath
+		}
+	}
+	pruneLogs(filepath.Dir(path))
+	return path
+}
+
+func pruneLogs(dir string) {
+	keep := defaultKeepLogs
+	if cfg, err := config.Load(); err == nil && cfg.KeepLogs > 0 {
+		keep = cfg.KeepLogs
+	}
+	logs, err := listLogs(dir)
+	if err != nil {
+		return
+	}
+	for len(logs) > keep {
+		os.Remove(logs[0])
+		logs = logs[1:]
+	}
+}
+
+// listLogs returns the logs in dir, oldest first.
+func listLogs(dir string) ([]string, error) {
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(logs)
+	return logs, nil
+}
+
+// ShowLog lists or prints the build logs of a package and returns the exit status.
+func ShowLog(name string, last, failed bool) int {
+	if name == "" {
+		fmt.Println("Error: no package specified")
+		return 1
+	}
+	// argon log runs without root, keep it inside the log directory
+	if name == "." || strings.Contains(name, "..") || strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		fmt.Printf("Error: invalid package name %q\n", name)
+		return 1
+	}
+	logs, err := listLogs(logDir(name))
+	if err != nil || len(logs) == 0 {
+		fmt.Printf("No build logs for %s\n", name)
+		return 0
+	}
+
+	if !last && !failed {
+		fmt.Printf("Build logs for %s:\n", name)
+		for _, log := range logs {
+			status := "ok"
+			if strings.HasSuffix(log, failedLogSuffix) {
+				status = "failed"
+			}
+			fmt.Printf("  %-7s %s\n", status, log)
+		}
+		return 0
+	}
+
+	var show string
+	for i := len(logs) - 1; i >= 0; i-- {
+		if !failed || strings.HasSuffix(logs[i], failedLogSuffix) {
+			show = logs[i]
+			break
+		}
+	}
+	if show == "" {
+		fmt.Printf("No failed builds logged for %s\n", name)
+		return 0
+	}
+
+	f, err := os.Open(show)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	fmt.Printf("==> %s\n", show)
+	io.Copy(os.Stdout, f)
+	return 0
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"argon-go/cli"
+	"argon-go/utils"
+)
+
+const cacheEntryFile = "entry.json"
+
+// cacheEntry describes a cached build next to its binary.
+type cacheEntry struct {
+	Name        string    `json:"name"`
+	Repo        string    `json:"repo"`
+	Hash        string    `json:"hash,omitempty"`
+	BuildSystem string    `json:"build_system"`
+	Target      string    `json:"target"`
+	Created     time.Time `json:"created"`
+}
+
+// buildCacheKey hashes everything that decides what a build produces. It
+// returns "" for sources without a fixed revision, which are never cached.
+func buildCacheKey(b *buildContext, record utils.Package, system string, reqs []utils.Requirement, patches []string) string {
+	if record.Dirty || record.InPlace || (record.Hash == "" && record.Digest == "") {
+		return ""
+	}
+
+	lines := []string{
+		"repo " + record.Repo,
+		"hash " + record.Hash,
+		"digest " + record.Digest,
+		"subdir " + record.Subdir,
+		"system " + system,
+		"target " + b.target.String(),
+		fmt.Sprintf("static %t", record.Static),
+	}
+	paths := make([]string, 0, len(record.Submodules))
+	for path := range record.Submodules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		lines = append(lines, "submodule "+path+" "+record.Submodules[path])
+	}
+
+	// the patches applyPatches applied, in order
+	for _, patch := range patches {
+		sum, err := fileSHA256(patch)
+		if err != nil {
+			return ""
+		}
+		lines = append(lines, "patch "+sum)
+	}
+
+	for _, kv := range b.build.Environ() {
+		lines = append(lines, "env "+kv)
+	}
+	lines = append(lines,
+		"make_args "+strings.Join(b.build.MakeArgs, " "),
+		"cmake_args "+strings.Join(b.build.CMakeArgs, " "),
+	)
+	for _, req := range reqs {
+		version, err := utils.ToolVersion(req.Command)
+		if err != nil {
+			version = "unknown"
+		}
+		lines = append(lines, "tool "+req.Command+" "+version)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func cacheEntryDir(key string) string {
+	return filepath.Join(utils.ArgonBuildCacheDir, key)
+}
+
+// cachedBuild returns the cached binary for key, or "" on a miss.
+func cachedBuild(key, name string) string {
+	path := filepath.Join(cacheEntryDir(key), name)
+	if !utils.FileExists(path) || !utils.FileExists(filepath.Join(cacheEntryDir(key), cacheEntryFile)) {
+		return ""
+	}
+	return path
+}
+
+// storeBuild adds a built binary to the cache, replacing the entry as a whole.
+func storeBuild(key, binaryPath string, record utils.Package) error {
+	if err := os.MkdirAll(utils.ArgonBuildCacheDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(utils.ArgonBuildCacheDir, ".new-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, record.Name), data, 0755); err != nil {
+		return err
+	}
+	entry := cacheEntry{
+		Name:        record.Name,
+		Repo:        record.Repo,
+		Hash:        record.Hash,
+		BuildSystem: record.BuildSystem,
+		Target:      record.Target,
+		Created:     time.Now(),
+	}
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, cacheEntryFile), meta, 0644); err != nil {
+		return err
+	}
+
+	dest := cacheEntryDir(key)
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+func HandleCache(args *cli.CacheArgs) {
+	switch args.Action {
+	case "prune":
+		CachePrune(args.All)
+	case "":
+		fmt.Println("Error: no cache command specified (try: argon cache prune)")
+	default:
+		fmt.Printf("Error: unknown cache command %q\n", args.Action)
+	}
+}
+
+// CachePrune drops cached builds no installed package uses, or every one
+// with all set.
+func CachePrune(all bool) {
+	keep := map[string]bool{}
+	if !all {
+		for _, pkg := range utils.GetInstalledPackages() {
+			if pkg.CacheKey != "" {
+				keep[pkg.CacheKey] = true
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(utils.ArgonBuildCacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("Build cache is empty")
+			return
+		}
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	var removed, kept int
+	var freed int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if keep[entry.Name()] {
+			kept++
+			continue
+		}
+		dir := filepath.Join(utils.ArgonBuildCacheDir, entry.Name())
+		size := dirSize(dir)
+		if err := os.RemoveAll(dir); err != nil {
+			fmt.Printf("Warning: could not remove %s: %v\n", dir, err)
+			continue
+		}
+		removed++
+		freed += size
+	}
+	fmt.Printf("Removed %d cached builds (%.1f MiB), kept %d\n", removed, float64(freed)/(1<<20), kept)
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
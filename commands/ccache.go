@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"argon-go/utils"
+)
+
+const compilerCacheOff = "off"
+
+// compilerCache wraps compilers so rebuilds only recompile what changed.
+type compilerCache struct {
+	// c wraps the C and C++ compilers, rust wraps rustc; either may be empty
+	c    string
+	rust string
+}
+
+// resolveCompilerCache picks the installed caches for a setting of "" (any),
+// "ccache", "sccache" or "off".
+func resolveCompilerCache(setting string, sandboxed bool) (compilerCache, error) {
+	var cache compilerCache
+	installed := func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+
+	switch setting {
+	case compilerCacheOff:
+		return cache, nil
+	case "", "ccache", "sccache":
+	default:
+		return cache, fmt.Errorf("unknown compiler cache %q (use ccache, sccache or off)", setting)
+	}
+	// sccache talks to a local server, which an isolated network namespace cuts off
+	sccache := setting != "ccache" && !sandboxed && installed("sccache")
+	if setting != "sccache" && installed("ccache") {
+		cache.c = "ccache"
+	} else if sccache {
+		cache.c = "sccache"
+	}
+	if sccache {
+		cache.rust = "sccache"
+	}
+	return cache, nil
+}
+
+func (c compilerCache) tools() []string {
+	switch {
+	case c.c == "":
+		return nil
+	case c.rust == "" || c.rust == c.c:
+		return []string{c.c}
+	}
+	return []string{c.c, c.rust}
+}
+
+// useCompilerCache points the caches at /var/cache/argon/<tool>/<user>/<name>
+// and hands them to the build user. Every build user and package gets its
+// own, so no build can plant objects in another package's binary.
+func (b *buildContext) useCompilerCache(name, setting string) error {
+	cache, err := resolveCompilerCache(setting, b.sandbox != nil && !b.sandbox.Network)
+	if err != nil {
+		return err
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("invalid package name %q for the compiler cache", name)
+	}
+	owner := "root"
+	if b.user != nil {
+		owner = b.user.Name
+	}
+	for _, tool := range cache.tools() {
+		dir := filepath.Join(utils.ArgonCacheDir, tool, owner, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for _, parent := range []string{filepath.Dir(filepath.Dir(dir)), filepath.Dir(dir)} {
+			if err := claimDir(parent); err != nil {
+				return err
+			}
+		}
+		if err := b.ownCacheDir(dir); err != nil {
+			return err
+		}
+		b.env = append(b.env, strings.ToUpper(tool)+"_DIR="+dir)
+		if b.sandbox != nil {
+			b.writable = append(b.writable, dir)
+		}
+	}
+	b.cache = cache
+	if tools := cache.tools(); len(tools) > 0 && b.log != nil {
+		fmt.Fprintf(b.log, "# compiler cache: %s\n", strings.Join(tools, ", "))
+	}
+	return nil
+}
+
+// claimDir makes sure dir is a real directory only root can change, as
+// older versions handed the whole cache to the build user.
+func claimDir(dir string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if err := os.Lchown(dir, 0, 0); err != nil {
+		return err
+	}
+	return os.Chmod(dir, 0755)
+}
+
+// ownCacheDir only walks the cache when another user owns it, since it
+// grows large and usually belongs to the build user already.
+func (b *buildContext) ownCacheDir(dir string) error {
+	if b.user == nil {
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid == b.user.Uid && st.Gid == b.user.Gid {
+		return nil
+	}
+	return b.user.Chown(dir)
+}
+
+// ccEnv wraps CC and CXX for builds that take the compiler from the environment.
+func (b *buildContext) ccEnv() []string {
+	if b.cache.c == "" {
+		return nil
+	}
+	compiler := func(name, fallback string) string {
+		if value := b.build.Env[name]; value != "" {
+			return value
+		}
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return fallback
+	}
+	return []string{
+		"CC=" + b.cache.c + " " + compiler("CC", "cc"),
+		"CXX=" + b.cache.c + " " + compiler("CXX", "c++"),
+	}
+}
+
+func (b *buildContext) rustEnv() []string {
+	if b.cache.rust == "" {
+		return nil
+	}
+	return []string{"RUSTC_WRAPPER=" + b.cache.rust}
+}
+
+func (b *buildContext) cmakeLaunchers() []string {
+	if b.cache.c == "" {
+		return nil
+	}
+	return []string{
+		"-DCMAKE_C_COMPILER_LAUNCHER=" + b.cache.c,
+		"-DCMAKE_CXX_COMPILER_LAUNCHER=" + b.cache.c,
+	}
+}
+
+// zeroCacheStats resets the counters so the summary covers this build only.
+func (b *buildContext) zeroCacheStats() {
+	for _, tool := range b.cache.tools() {
+		cmd := b.command("/", tool, "--zero-stats")
+		cmd.Stdout, cmd.Stderr = nil, nil
+		cmd.Run()
+	}
+}
+
+// cacheStats summarizes the hit rate of every cache the build used and
+// stops the sccache server the build started.
+func (b *buildContext) cacheStats() []string {
+	var summary []string
+	for _, tool := range b.cache.tools() {
+		var hits, misses int
+		var ok bool
+		switch tool {
+		case "ccache":
+			hits, misses, ok = b.ccacheStats()
+		case "sccache":
+			hits, misses, ok = b.sccacheStats()
+			stop := b.command("/", "sccache", "--stop-server")
+			stop.Stdout, stop.Stderr = nil, nil
+			stop.Run()
+		}
+		if !ok || hits+misses == 0 {
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s %d/%d hits (%d%%)", tool, hits, hits+misses, 100*hits/(hits+misses)))
+	}
+	return summary
+}
+
+func (b *buildContext) statsOutput(tool string, args ...string) ([]byte, bool) {
+	var out bytes.Buffer
+	cmd := b.command("/", tool, args...)
+	cmd.Stdout, cmd.Stderr = &out, nil
+	if err := cmd.Run(); err != nil {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+func (b *buildContext) ccacheStats() (int, int, bool) {
+	out, ok := b.statsOutput("ccache", "--print-stats")
+	if !ok {
+		return 0, 0, false
+	}
+	var hits, misses int
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "direct_cache_hit", "preprocessed_cache_hit":
+			hits += n
+		case "cache_miss":
+			misses += n
+		}
+	}
+	return hits, misses, true
+}
+
+func (b *buildContext) sccacheStats() (int, int, bool) {
+	out, ok := b.statsOutput("sccache", "--show-stats", "--stats-format", "json")
+	if !ok {
+		return 0, 0, false
+	}
+	var stats struct {
+		Stats struct {
+			CacheHits   struct{ Counts map[string]int } `json:"cache_hits"`
+			CacheMisses struct{ Counts map[string]int } `json:"cache_misses"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(out, &stats); err != nil {
+		return 0, 0, false
+	}
+	var hits, misses int
+	for _, n := range stats.Stats.CacheHits.Counts {
+		hits += n
+	}
+	for _, n := range stats.Stats.CacheMisses.Counts {
+		misses += n
+	}
+	return hits, misses, true
+}
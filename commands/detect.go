@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"argon-go/utils"
+)
+
+// buildFileOrder is the order candidates are listed in when they score the same.
+var buildFileOrder = []string{"build.zig", "Cargo.toml", "CMakeLists.txt", "configure", "configure.ac", "configure.in", "Makefile", "makefile", "build.sh"}
+
+// baseScores rank the build systems when nothing else tells them apart;
+// the dedicated ones go first since a Makefile or build.sh next to them
+// usually just wraps them.
+var baseScores = map[string]int{
+	"zig":       70,
+	"cargo":     65,
+	"cmake":     60,
+	"configure": 55,
+	"make":      50,
+	"shell":     45,
+	"autotools": 40,
+}
+
+// commonSubdirs are searched when projects keep their build below the root.
+var commonSubdirs = []string{"src", "source", "build", "unix", "linux"}
+
+const (
+	generatedPenalty = 40
+	subdirPenalty    = 25
+	parentPenalty    = 30
+	// a virtual workspace has nothing to build at the root, so it ranks
+	// below any other build file and a package in a common subdir
+	virtualWorkspacePenalty = 30
+)
+
+// candidate is a build file argon could use.
+type candidate struct {
+	file    string
+	dir     string
+	system  string
+	score   int
+	reasons []string
+}
+
+func (c candidate) String() string {
+	return fmt.Sprintf("%s (%s)", c.system, strings.Join(c.reasons, ", "))
+}
+
+// detectBuildSystems scores the build files in dir and its common subdirs,
+// falling back to the closest parent with any, best first.
+func detectBuildSystems(dir string) []candidate {
+	candidates := scanDir(dir)
+	for _, sub := range commonSubdirs {
+		for _, c := range scanDir(filepath.Join(dir, sub)) {
+			c.score -= subdirPenalty
+			c.reasons = append(c.reasons, "in "+sub+"/")
+			candidates = append(candidates, c)
+		}
+	}
+
+	for parent := filepath.Dir(dir); len(candidates) == 0; parent = filepath.Dir(parent) {
+		for _, c := range scanDir(parent) {
+			c.score -= parentPenalty
+			c.reasons = append(c.reasons, "in parent "+parent)
+			candidates = append(candidates, c)
+		}
+		if parent == filepath.Dir(parent) {
+			break
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	return candidates
+}
+
+func scanDir(dir string) []candidate {
+	var candidates []candidate
+	has := func(name string) bool {
+		return utils.FileExists(filepath.Join(dir, name))
+	}
+	for _, name := range buildFileOrder {
+		if !has(name) {
+			continue
+		}
+		c := candidate{file: filepath.Join(dir, name), dir: dir, system: buildSystems[name]}
+		c.score = baseScores[c.system]
+
+		switch c.system {
+		case "make":
+			if from := generatedMakefile(dir, c.file); from != "" {
+				c.score -= generatedPenalty
+				c.reasons = append(c.reasons, name+" "+from)
+			} else {
+				c.reasons = append(c.reasons, "hand-written "+name)
+			}
+		case "configure":
+			if has("configure.ac") || has("configure.in") {
+				c.reasons = append(c.reasons, "configure script generated by autoconf")
+			} else {
+				c.reasons = append(c.reasons, "configure script")
+			}
+		case "autotools":
+			// configure is preferred when it was shipped
+			if has("configure") {
+				continue
+			}
+			c.reasons = append(c.reasons, name+" without configure, needs autoreconf")
+		case "cargo":
+			kind, virtual := cargoKind(c.file)
+			if virtual {
+				c.score -= virtualWorkspacePenalty
+			}
+			c.reasons = append(c.reasons, kind)
+		default:
+			c.reasons = append(c.reasons, name)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// generatedMakefile says what generated a Makefile, or "" for one written by hand.
+func generatedMakefile(dir, path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 20 && scanner.Scan(); i++ {
+		line := strings.ToLower(scanner.Text())
+		switch {
+		case strings.Contains(line, "generated by automake"):
+			return "generated by automake"
+		case strings.Contains(line, "cmake generated file") || strings.Contains(line, "generated by \"unix makefiles\""):
+			return "generated by CMake"
+		case strings.Contains(line, "generated by") || strings.Contains(line, "do not edit"):
+			return "generated by a tool"
+		}
+	}
+	for _, source := range []string{"Makefile.in", "Makefile.am", "config.status"} {
+		if utils.FileExists(filepath.Join(dir, source)) {
+			return "generated from " + source
+		}
+	}
+	return ""
+}
+
+// cargoKind describes a Cargo.toml and says whether it is a virtual
+// workspace, one without a [package] of its own.
+func cargoKind(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "Cargo.toml", false
+	}
+	var workspace, pkg bool
+	for _, line := range strings.Split(string(data), "\n") {
+		switch strings.TrimSpace(line) {
+		case "[workspace]":
+			workspace = true
+		case "[package]":
+			pkg = true
+		}
+	}
+	switch {
+	case workspace && pkg:
+		return "cargo package at a workspace root", false
+	case workspace:
+		return "virtual cargo workspace", true
+	}
+	return "cargo package", false
+}
+
+// tiedBest returns the candidates sharing the best score.
+func tiedBest(candidates []candidate) []candidate {
+	n := 1
+	for n < len(candidates) && candidates[n].score == candidates[0].score {
+		n++
+	}
+	return candidates[:n]
+}
@@ -11,10 +11,14 @@ func Help(osArgs []string) {
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  install <package> [options]  Install a package (requires sudo)")
+	fmt.Println("                                <package> may be owner/repo, a git URL, a local path or an archive URL")
+	fmt.Println("                                prefixes: gh:owner/repo gl:group/sub/repo cb:owner/repo srht:~user/repo")
 	fmt.Println("  list                          List installed packages")
 	fmt.Println("  remove <package>              Remove a package (requires sudo)")
 	fmt.Println("  search <query>                Search for packages")
 	fmt.Println("  upgrade                       Upgrade installed packages (requires sudo)")
+	fmt.Println("  log <package> [--last|--failed] Show build logs")
+	fmt.Println("  cache prune [--all]           Remove unused cached builds (requires sudo)")
 	fmt.Println("  help                          Display this help message")
 	fmt.Println()
 	fmt.Println("For help with a specific command:")
@@ -22,6 +26,8 @@ func Help(osArgs []string) {
 	fmt.Println("  argon remove --help")
 	fmt.Println("  argon upgrade --help")
 	fmt.Println("  argon search --help")
+	fmt.Println("  argon log --help")
+	fmt.Println("  argon cache --help")
 	
 	if len(osArgs) > 2 {
 		cmd := osArgs[1]
@@ -37,11 +43,33 @@ func Help(osArgs []string) {
 				fmt.Println("  --yes           Skip confirmation prompts")
 				fmt.Println("  --pkgdeps <file> Install packages from file")
 				fmt.Println("  --static        Build static binary")
+				fmt.Println("  --track <t>     Upgrade channel: head, branch:<name>, latest-tag[:<glob|semver>] or ref:<ref>")
+				fmt.Println("  --smoke <args>  Run the built binary with <args> before installing")
+				fmt.Println("  --smoke-expect <re> Regex the smoke test output must match")
+				fmt.Println("  --in-place      Build a local path in place instead of a snapshot copy")
+				fmt.Println("  --sha256 <sum>  Expected digest of a .tar.gz/.tar.xz/.tar.zst/.zip archive")
+				fmt.Println("  --vcs <name>    git (default), hg or fossil; or prefix the URL with hg+ / fossil+")
+				fmt.Println("  --subdir <dir>  Build a subproject of a monorepo (or use owner/repo//tools/foo)")
+				fmt.Println("  --pr <n>        Install pull/merge request <n>; upgrades follow it until reinstalled")
+				fmt.Println("  --ref <ref>     Install and follow an arbitrary ref like refs/pull/1/head")
+				fmt.Println("  --sandbox       Build without network on a read-only root (kept for upgrades)")
+				fmt.Println("  --quiet         Only write build output to /var/lib/argon/logs")
+				fmt.Println("  -j, --jobs <n>  Parallel build jobs, kept for upgrades (default: number of CPUs)")
+				fmt.Println("  --target <t>    Build for arch[-linux-gnu|musl], e.g. aarch64 (kept for upgrades)")
+				fmt.Println("  --rebuild       Build even if /var/cache/argon/builds has a matching binary")
 			case "upgrade":
 				fmt.Println()
 				fmt.Println("Upgrade options:")
 				fmt.Println("  --local         Upgrade local installations only")
 				fmt.Println("  --yes           Skip confirmation prompts")
+				fmt.Println("  --report <file> Write a JSON or JUnit (.xml) summary to file")
+				fmt.Println("  --report-format <fmt> Force report format: json or junit")
+				fmt.Println("  --quiet         Only write build output to /var/lib/argon/logs")
+				fmt.Println()
+				fmt.Println("Exit status:")
+				fmt.Println("  0    nothing to do")
+				fmt.Println("  100  one or more packages upgraded")
+				fmt.Println("  1    one or more packages failed")
 			case "remove":
 				fmt.Println()
 				fmt.Println("Remove options:")
@@ -50,6 +78,17 @@ func Help(osArgs []string) {
 				fmt.Println()
 				fmt.Println("Search options:")
 				fmt.Println("  <query>         Search query")
+			case "log":
+				fmt.Println()
+				fmt.Println("Log options:")
+				fmt.Println("  <package>       List the build logs of a package")
+				fmt.Println("  --last          Show the most recent build log")
+				fmt.Println("  --failed        Show the most recent failed build log")
+			case "cache":
+				fmt.Println()
+				fmt.Println("Cache commands:")
+				fmt.Println("  prune           Remove cached builds no installed package uses")
+				fmt.Println("  prune --all     Remove every cached build")
 			}
 		}
 	}
@@ -5,33 +5,73 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"argon-go/cli"
-	"argon-go/pkgconfig"
 	"argon-go/utils"
+	"argon-go/vcs"
 )
 
-func cloneRepo(pkg, branch, buildDir string) error {
-	domain := utils.GetDomainFromURL(pkg)
-	repoPath := utils.ExtractRepoPath(pkg)
-	url := fmt.Sprintf("https://%s/%s", domain, repoPath)
+func sourceBackend(pkg string) (vcs.VCS, utils.Remote, error) {
+	remote, err := utils.ParseRemote(pkg)
+	if err != nil {
+		return nil, remote, err
+	}
+	backend, err := vcs.ForRemote(remote)
+	return backend, remote, err
+}
+
+func cloneRepo(pkg, branch, buildDir, subdir string) error {
+	backend, remote, err := sourceBackend(pkg)
+	if err != nil {
+		return err
+	}
 	
-	args := []string{"clone", "--depth=1"}
-	if branch != "" {
-		args = append(args, "--branch", branch)
+	cacheDir := utils.SourceCachePath(remote)
+	if err := backend.Fetch(remote.FetchURLs(), cacheDir); err != nil {
+		return fmt.Errorf("failed to update source cache: %w", err)
+	}
+	if strings.HasPrefix(branch, "refs/") {
+		fetcher, ok := backend.(vcs.RefFetcher)
+		if !ok {
+			return fmt.Errorf("%s cannot fetch %s", backend.Name(), branch)
+		}
+		if err := fetcher.FetchRef(remote.FetchURLs(), cacheDir, branch); err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", branch, err)
+		}
 	}
-	args = append(args, url, buildDir)
 	
-	cmd := exec.Command("git", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if err := vcs.CloneSubdir(backend, cacheDir, buildDir, branch, subdir); err != nil {
+		return err
+	}
+	if subdir != "" && !utils.DirectoryExists(filepath.Join(buildDir, subdir)) {
+		return fmt.Errorf("subdirectory %s not found in %s", subdir, pkg)
+	}
+	return nil
+}
+
+func sourceRevision(pkg, dir string) (string, error) {
+	backend, _, err := sourceBackend(pkg)
+	if err != nil {
+		return "", err
+	}
+	return backend.Revision(dir)
+}
+
+func remoteHash(pkg string, track utils.Track) (string, string, error) {
+	backend, remote, err := sourceBackend(pkg)
+	if err != nil {
+		return "", "", err
+	}
+	return vcs.RemoteHash(backend, remote, track)
 }
 
 func handleExistingDir(buildDir string) (bool, error) {
@@ -71,199 +111,198 @@ func handleExistingDir(buildDir string) (bool, error) {
 	}
 }
 
-func applyPatches(buildDir, patchesDir string) error {
-	if patchesDir == "" {
+// ownedByOthers reports whether anything in dir belongs to another account.
+func ownedByOthers(dir string) bool {
+	uid := uint32(os.Geteuid())
+	others := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		var info fs.FileInfo
+		if err == nil {
+			info, err = d.Info()
+		}
+		if err != nil {
+			others = true
+			return filepath.SkipAll
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); !ok || st.Uid != uid {
+			others = true
+			return filepath.SkipAll
+		}
 		return nil
+	})
+	return others
+}
+
+// listPatches returns the patches below patchesDir in the order they are applied.
+func listPatches(patchesDir string) ([]string, error) {
+	if patchesDir == "" {
+		return nil, nil
 	}
 	
 	if !utils.DirectoryExists(patchesDir) {
-		return fmt.Errorf("patches directory does not exist: %s", patchesDir)
+		return nil, fmt.Errorf("patches directory does not exist: %s", patchesDir)
 	}
 	
 	cleanPatchesDir := filepath.Clean(patchesDir)
 	if strings.Contains(cleanPatchesDir, "..") {
-		return fmt.Errorf("invalid patches directory path")
+		return nil, fmt.Errorf("invalid patches directory path")
 	}
 	
 	cmd := exec.Command("find", cleanPatchesDir, "-name", "*.patch", "-type", "f")
 	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to find patches: %w", err)
+		return nil, fmt.Errorf("failed to find patches: %w", err)
 	}
 	
-	patches := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, patch := range patches {
-		if patch == "" {
-			continue
+	var patches []string
+	for _, patch := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if patch != "" {
+			patches = append(patches, patch)
 		}
+	}
+	return patches, nil
+}
+
+// applyPatches returns the patches it applied, which is all of them or an error.
+func applyPatches(buildDir, patchesDir string) ([]string, error) {
+	patches, err := listPatches(patchesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, patch := range patches {
 		patchCmd := exec.Command("patch", "-Np1", "-i", patch)
 		patchCmd.Dir = buildDir
 		patchCmd.Stdout = os.Stdout
 		patchCmd.Stderr = os.Stderr
 		if err := patchCmd.Run(); err != nil {
-			return fmt.Errorf("failed to apply patch %s: %w", patch, err)
+			return nil, fmt.Errorf("failed to apply patch %s: %w", patch, err)
 		}
 	}
 	
-	return nil
+	return patches, nil
 }
 
-func buildWithMake(buildDir, repoName, cflags, libs string, static bool) (string, error) {
-	env := os.Environ()
-	
-	if static {
-		staticCflags := "-static"
-		if cflags != "" {
-			staticCflags = cflags + " -static"
-		}
-		env = append(env, "CFLAGS="+staticCflags)
-		
-		staticLdflags := "-static"
-		if libs != "" {
-			staticLdflags = libs + " -static"
-		}
-		env = append(env, "LDFLAGS="+staticLdflags)
-	} else {
-		if cflags != "" {
-			env = append(env, "CFLAGS="+cflags)
-		}
-		if libs != "" {
-			env = append(env, "LDFLAGS="+libs)
-		}
-	}
-	
-	cmd := exec.Command("make")
-	cmd.Dir = buildDir
-	cmd.Env = env
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func buildWithMake(b *buildContext, buildDir string) (string, error) {
+	cmd := b.command(buildDir, "make", b.makeArgs()...)
+	cmd.Env = append(cmd.Env, b.ccEnv()...)
 	return "make", cmd.Run()
 }
 
-func buildWithCargo(buildDir string, static bool) (string, error) {
-	args := []string{"build", "--release"}
+func buildWithCargo(b *buildContext, buildDir string) (string, error) {
+	args := []string{"build", "--release", "-j", strconv.Itoa(b.jobs)}
 	
-	if static {
-		target := fmt.Sprintf("%s-unknown-linux-musl", runtime.GOARCH)
+	if !b.target.Native() {
+		target := b.target.Rust()
 		args = append(args, "--target", target)
 		
-		installTarget := exec.Command("rustup", "target", "add", target)
+		installTarget := b.fetchCommand(buildDir, "rustup", "target", "add", target)
 		installTarget.Stdout = io.Discard
 		installTarget.Stderr = io.Discard
 		installTarget.Run() 
 	}
 	
-	cmd := exec.Command("cargo", args...)
-	cmd.Dir = buildDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := b.command(buildDir, "cargo", args...)
+	cmd.Env = append(cmd.Env, b.rustEnv()...)
 	return "cargo", cmd.Run()
 }
 
-func buildWithCMake(buildDir string, static bool) (string, error) {
+func buildWithCMake(b *buildContext, buildDir string, static bool) (string, error) {
 	buildPath := filepath.Join(buildDir, "build")
 	if err := os.MkdirAll(buildPath, 0755); err != nil {
 		return "cmake", err
 	}
+	if err := b.own(buildPath); err != nil {
+		return "cmake", err
+	}
 	
 	cmakeArgs := []string{".."}
 	if static {
 		cmakeArgs = append(cmakeArgs, "-DCMAKE_EXE_LINKER_FLAGS=-static", "-DBUILD_SHARED_LIBS=OFF")
 	}
+	if b.target.Cross() {
+		// kept out of the build tree, which the repository controls
+		toolchainDir, err := os.MkdirTemp(filepath.Join(utils.ArgonTempDir, "tmp"), "toolchain-")
+		if err != nil {
+			return "cmake", err
+		}
+		defer os.RemoveAll(toolchainDir)
+		if err := os.Chmod(toolchainDir, 0755); err != nil {
+			return "cmake", err
+		}
+		toolchain := filepath.Join(toolchainDir, "argon-toolchain.cmake")
+		if err := os.WriteFile(toolchain, []byte(b.target.CMakeToolchain()), 0644); err != nil {
+			return "cmake", err
+		}
+		cmakeArgs = append(cmakeArgs, "-DCMAKE_TOOLCHAIN_FILE="+toolchain)
+	}
+	cmakeArgs = append(cmakeArgs, b.cmakeLaunchers()...)
+	cmakeArgs = append(cmakeArgs, b.build.CMakeArgs...)
 	
-	cmd1 := exec.Command("cmake", cmakeArgs...)
-	cmd1.Dir = buildPath
-	cmd1.Stdout = os.Stdout
-	cmd1.Stderr = os.Stderr
-	if err := cmd1.Run(); err != nil {
+	if err := b.command(buildPath, "cmake", cmakeArgs...).Run(); err != nil {
 		return "cmake", err
 	}
 	
-	cmd2 := exec.Command("make")
-	cmd2.Dir = buildPath
-	cmd2.Stdout = os.Stdout
-	cmd2.Stderr = os.Stderr
-	return "cmake", cmd2.Run()
+	return "cmake", b.command(buildPath, "cmake", "--build", ".", "--parallel", strconv.Itoa(b.jobs)).Run()
 }
 
-func buildWithConfigure(buildDir string, static bool) (string, error) {
+func buildWithConfigure(b *buildContext, buildDir string, static bool) (string, error) {
+	// -static reaches configure through LDFLAGS in the build environment
 	configureArgs := []string{"./configure"}
 	if static {
-		configureArgs = append(configureArgs, "LDFLAGS=-static", "--disable-shared")
+		configureArgs = append(configureArgs, "--disable-shared")
+	}
+	if b.target.Cross() {
+		configureArgs = append(configureArgs, "--host="+b.target.GNUTriple())
 	}
 	
-	cmd1 := exec.Command(configureArgs[0], configureArgs[1:]...)
-	cmd1.Dir = buildDir
-	cmd1.Stdout = os.Stdout
-	cmd1.Stderr = os.Stderr
-	if err := cmd1.Run(); err != nil {
+	configure := b.command(buildDir, configureArgs[0], configureArgs[1:]...)
+	configure.Env = append(configure.Env, b.ccEnv()...)
+	if err := configure.Run(); err != nil {
 		return "configure", err
 	}
 	
-	cmd2 := exec.Command("make")
-	cmd2.Dir = buildDir
-	cmd2.Stdout = os.Stdout
-	cmd2.Stderr = os.Stderr
-	return "configure", cmd2.Run()
+	makeCmd := b.command(buildDir, "make", b.makeArgs()...)
+	makeCmd.Env = append(makeCmd.Env, b.ccEnv()...)
+	return "configure", makeCmd.Run()
 }
 
-func buildWithZig(buildDir string, static bool) (string, error) {
-	args := []string{"build"}
-	if static {
-		args = append(args, "-Dtarget=native-native-musl")
+func buildWithAutotools(b *buildContext, buildDir string, static bool) (string, error) {
+	if err := b.command(buildDir, "autoreconf", "-fi").Run(); err != nil {
+		return "autotools", err
+	}
+	_, err := buildWithConfigure(b, buildDir, static)
+	return "autotools", err
+}
+
+func buildWithZig(b *buildContext, buildDir string) (string, error) {
+	args := []string{"build", b.jobsFlag()}
+	if !b.target.Native() {
+		args = append(args, "-Dtarget="+b.target.Zig())
 	}
 	
-	cmd := exec.Command("zig", args...)
-	cmd.Dir = buildDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return "zig", cmd.Run()
+	return "zig", b.command(buildDir, "zig", args...).Run()
 }
 
-func buildWithShellScript(buildDir string, static bool) (string, error) {
+func buildWithShellScript(b *buildContext, buildDir string, static bool) (string, error) {
 	scriptPath := filepath.Join(buildDir, "build.sh")
+	if info, err := os.Lstat(scriptPath); err != nil || !info.Mode().IsRegular() {
+		return "shell", fmt.Errorf("build.sh is not a regular file")
+	}
 	
-	if err := os.Chmod(scriptPath, 0755); err != nil {
+	// as the build user, root would follow whatever the repository put there
+	if err := b.command(buildDir, "chmod", "+x", "build.sh").Run(); err != nil {
 		return "shell", fmt.Errorf("failed to make build.sh executable: %w", err)
 	}
 	
-	env := os.Environ()
+	cmd := b.command(buildDir, "./build.sh")
+	cmd.Env = append(cmd.Env, b.ccEnv()...)
+	cmd.Env = append(cmd.Env, b.rustEnv()...)
 	if static {
-		env = append(env, "STATIC_BUILD=1")
+		cmd.Env = append(cmd.Env, "STATIC_BUILD=1")
 	}
-	
-	cmd := exec.Command("./build.sh")
-	cmd.Dir = buildDir
-	cmd.Env = env
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 	return "shell", cmd.Run()
 }
 
-func findBuildFilesRecursive(startDir string) ([]string, string) {
-	buildFiles := []string{}
-	knownFiles := []string{"Makefile", "makefile", "Cargo.toml", "CMakeLists.txt", "configure", "build.zig", "build.sh"}
-
-	currentDir := startDir
-	for {
-		for _, file := range knownFiles {
-			path := filepath.Join(currentDir, file)
-			if utils.FileExists(path) {
-				buildFiles = append(buildFiles, path)
-			}
-		}
-		if len(buildFiles) > 0 {
-			return buildFiles, currentDir
-		}
-		parent := filepath.Dir(currentDir)
-		if parent == currentDir {
-			break
-		}
-		currentDir = parent
-	}
-	return buildFiles, ""
-}
-
 func displayBuildFileWithLess(filepath string) error {
 	cmd := exec.Command("less", filepath)
 	cmd.Stdin = os.Stdin
@@ -283,26 +322,31 @@ func confirmBuild() bool {
 	return response == "y" || response == "yes"
 }
 
-func detectAndBuild(buildDir, repoName string, static bool) (string, error) {
-	if !pkgconfig.CheckPkgConfigExists() {
-		fmt.Println("Warning: pkg-config not found in PATH")
-	}
-
-	cflags, libs := pkgconfig.GetFlags(repoName, static)
-
-	buildFiles, foundDir := findBuildFilesRecursive(buildDir)
-	if len(buildFiles) == 0 {
-		return "", fmt.Errorf("no supported build system found")
+// selectBuild picks the build file, asking only when candidates tie.
+func selectBuild(b *buildContext, buildDir string, yes bool) (candidate, error) {
+	candidates := detectBuildSystems(buildDir)
+	if len(candidates) == 0 {
+		return candidate{}, fmt.Errorf("no supported build system found")
+	}
+	if b.system != "" {
+		var matching []candidate
+		for _, c := range candidates {
+			if c.system == b.system {
+				matching = append(matching, c)
+			}
+		}
+		if len(matching) == 0 {
+			return candidate{}, fmt.Errorf("the recipe asks for %s but no %s build file was found", b.system, b.system)
+		}
+		candidates = matching
 	}
 
-	var selectedBuildFile string
-	if len(buildFiles) == 1 {
-		selectedBuildFile = buildFiles[0]
-	} else {
-		fmt.Println("Multiple build files found:")
-		for i, file := range buildFiles {
-			rel, _ := filepath.Rel(buildDir, file)
-			fmt.Printf("%d. %s\n", i+1, rel)
+	selected := candidates[0]
+	if tied := tiedBest(candidates); len(tied) > 1 && !yes {
+		fmt.Println("Build files found that fit equally well:")
+		for i, c := range tied {
+			rel, _ := filepath.Rel(buildDir, c.file)
+			fmt.Printf("%d. %s: %s\n", i+1, rel, c)
 		}
 		fmt.Print("Select build file [1]: ")
 		reader := bufio.NewReader(os.Stdin)
@@ -313,48 +357,67 @@ func detectAndBuild(buildDir, repoName string, static bool) (string, error) {
 			fmt.Sscanf(choice, "%d", &index)
 			index--
 		}
-		if index < 0 || index >= len(buildFiles) {
+		if index < 0 || index >= len(tied) {
 			index = 0
 		}
-		selectedBuildFile = buildFiles[index]
+		selected = tied[index]
+	} else {
+		b.printf("Detected %s\n", selected)
+		for _, c := range candidates[1:] {
+			b.printf("  also found %s\n", c)
+		}
 	}
 
-	fmt.Printf("Using build file: %s\n", selectedBuildFile)
-	fmt.Println("Displaying build file with less (press q to continue)...")
-	if err := displayBuildFileWithLess(selectedBuildFile); err != nil {
-		fmt.Printf("Warning: could not display with less: %v\n", err)
+	b.printf("Using build file: %s\n", selected.file)
+	return selected, nil
+}
+
+// runBuild checks the toolchain, lets the user review the build file and
+// builds in the selected directory.
+func runBuild(b *buildContext, selected candidate, reqs []utils.Requirement, static, yes bool) error {
+	if err := checkToolchain(selected.system, reqs); err != nil {
+		return err
 	}
+	if !yes {
+		fmt.Println("Displaying build file with less (press q to continue)...")
+		if err := displayBuildFileWithLess(selected.file); err != nil {
+			fmt.Printf("Warning: could not display with less: %v\n", err)
+		}
 
-	if !confirmBuild() {
-		return "", fmt.Errorf("build cancelled by user")
+		if !confirmBuild() {
+			return fmt.Errorf("build cancelled by user")
+		}
 	}
 
-	buildDir = foundDir
-	filename := filepath.Base(selectedBuildFile)
+	buildDir := selected.dir
+	if err := b.prefetch(buildDir, filepath.Base(selected.file)); err != nil {
+		return err
+	}
 
-	switch filename {
-	case "Makefile", "makefile":
-		return buildWithMake(buildDir, repoName, cflags, libs, static)
-	case "Cargo.toml":
-		return buildWithCargo(buildDir, static)
-	case "CMakeLists.txt":
-		return buildWithCMake(buildDir, static)
+	var err error
+	switch selected.system {
+	case "make":
+		_, err = buildWithMake(b, buildDir)
+	case "cargo":
+		_, err = buildWithCargo(b, buildDir)
+	case "cmake":
+		_, err = buildWithCMake(b, buildDir, static)
 	case "configure":
-		return buildWithConfigure(buildDir, static)
-	case "build.zig":
-		return buildWithZig(buildDir, static)
-	case "build.sh":
-		return buildWithShellScript(buildDir, static)
+		_, err = buildWithConfigure(b, buildDir, static)
+	case "autotools":
+		_, err = buildWithAutotools(b, buildDir, static)
+	case "zig":
+		_, err = buildWithZig(b, buildDir)
+	case "shell":
+		_, err = buildWithShellScript(b, buildDir, static)
 	default:
-		return "", fmt.Errorf("unsupported build file: %s", filename)
+		return fmt.Errorf("unsupported build file: %s", selected.file)
 	}
+	return err
 }
 
-func findBinary(buildDir, repoName string, static bool) (string, error) {
-	targetDir := "release"
-	if static {
-		targetDir = fmt.Sprintf("%s-unknown-linux-musl/release", runtime.GOARCH)
-	}
+func findBinary(buildDir, repoName string, target utils.Target) (string, error) {
+	targetDir := filepath.Join(target.Rust(), "release")
 	
 	exactPaths := []string{
 		filepath.Join(buildDir, repoName),
@@ -364,7 +427,8 @@ func findBinary(buildDir, repoName string, static bool) (string, error) {
 	}
 	
 	for _, path := range exactPaths {
-		if utils.FileExists(path) {
+		// the build user owns the tree, never follow its symlinks
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
 			return path, nil
 		}
 	}
@@ -415,6 +479,9 @@ func findBinary(buildDir, repoName string, static bool) (string, error) {
 		"makefile": true,
 		"build.zig": true,
 		"Cargo.toml": true,
+		"config.status": true,
+		"libtool": true,
+		"autogen.sh": true,
 	}
 	
 	for _, entry := range entries {
@@ -441,12 +508,7 @@ func findBinary(buildDir, repoName string, static bool) (string, error) {
 	return binaries[0], nil
 }
 
-func installBinary(buildDir, repoName string, yes, static bool) error {
-	binaryPath, err := findBinary(buildDir, repoName, static)
-	if err != nil {
-		return err
-	}
-	
+func installBinary(binaryPath, repoName string) error {
 	destPath := filepath.Join("/usr/local/bin", repoName)
 	
 	sourceData, err := os.ReadFile(binaryPath)
@@ -454,7 +516,13 @@ func installBinary(buildDir, repoName string, yes, static bool) error {
 		return fmt.Errorf("failed to read binary: %w", err)
 	}
 	
-	if err := os.WriteFile(destPath, sourceData, 0755); err != nil {
+	tmpPath := destPath + ".argon-new"
+	if err := os.WriteFile(tmpPath, sourceData, 0755); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to install binary: %w", err)
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to install binary: %w", err)
 	}
 	
@@ -462,29 +530,101 @@ func installBinary(buildDir, repoName string, yes, static bool) error {
 	return nil
 }
 
-func addToPackageList(pkg, repoName, buildSystem, hash string, static bool) error {
+func addToPackageList(record utils.Package) error {
 	packages := utils.GetInstalledPackages()
 
 	for i, existingPkg := range packages {
-		if existingPkg.Name == repoName {
-			packages[i].Repo = pkg
-			packages[i].BuildSystem = buildSystem
-			packages[i].Hash = hash
-			packages[i].Static = static
+		if existingPkg.Name == record.Name {
+			packages[i] = record
 			return utils.SaveInstalledPackages(packages)
 		}
 	}
 
-	newPackage := utils.Package{
-		Name:        repoName,
-		Repo:        pkg,
-		BuildSystem: buildSystem,
-		Hash:        hash,
-		Static:      static,
+	packages = append(packages, record)
+	return utils.SaveInstalledPackages(packages)
+}
+
+func installedSmokeTest(name string) *utils.SmokeTest {
+	for _, pkg := range utils.GetInstalledPackages() {
+		if pkg.Name == name {
+			return pkg.Smoke
+		}
 	}
+	return nil
+}
 
-	packages = append(packages, newPackage)
-	return utils.SaveInstalledPackages(packages)
+func trackSpec(remote utils.Remote, args *cli.InstallArgs) (string, error) {
+	set := 0
+	for _, given := range []bool{args.Track != "", args.Branch != "", args.PR != 0, args.Ref != ""} {
+		if given {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", fmt.Errorf("use only one of --track, --branch, --pr and --ref")
+	}
+
+	switch {
+	case args.Branch != "":
+		return utils.TrackBranch + ":" + args.Branch, nil
+	case args.Ref != "":
+		return utils.TrackRef + ":" + args.Ref, nil
+	case args.PR != 0:
+		refs, err := utils.PullRequestRefs(remote, args.PR)
+		if err != nil {
+			return "", err
+		}
+		return utils.TrackRef + ":" + pullRequestRef(remote, refs), nil
+	}
+	return args.Track, nil
+}
+
+// pullRequestRef picks the first of refs the remote has, as a self-hosted
+// GitLab doesn't give itself away by its host name.
+func pullRequestRef(remote utils.Remote, refs []string) string {
+	backend, err := vcs.ForRemote(remote)
+	if err != nil {
+		return refs[0]
+	}
+	for _, ref := range refs {
+		if _, _, err := vcs.RemoteHash(backend, remote, utils.Track{Kind: utils.TrackRef, Ref: ref}); err == nil {
+			return ref
+		}
+	}
+	return refs[0]
+}
+
+func resolveTrack(pkg string, args *cli.InstallArgs) (utils.Track, string, error) {
+	remote, err := utils.ParseRemote(pkg)
+	if err != nil {
+		return utils.Track{}, "", err
+	}
+	spec, err := trackSpec(remote, args)
+	if err != nil {
+		return utils.Track{}, "", err
+	}
+	track, err := utils.ParseTrack(spec)
+	if err != nil {
+		return track, "", err
+	}
+	
+	switch track.Kind {
+	case utils.TrackBranch:
+		return track, track.Branch, nil
+	case utils.TrackRef:
+		if args.PR != 0 {
+			fmt.Printf("Installing pull request #%d (%s)\n", args.PR, track.Ref)
+		}
+		return track, track.Ref, nil
+	case utils.TrackLatestTag:
+		_, tag, err := remoteHash(pkg, track)
+		if err != nil {
+			return track, "", fmt.Errorf("failed to resolve %s: %w", track, err)
+		}
+		fmt.Printf("Tracking %s: using tag %s\n", track, tag)
+		return track, tag, nil
+	}
+	return track, "", nil
 }
 
 func installSingle(ctx context.Context, pkg string, args *cli.InstallArgs) error {
@@ -498,57 +638,258 @@ func installSingle(ctx context.Context, pkg string, args *cli.InstallArgs) error
 	default:
 	}
 
+	pkg, subdir := utils.SplitSubdir(pkg)
+	if args.Subdir != "" {
+		subdir = args.Subdir
+	}
+	subdir, err := utils.CleanSubdir(subdir)
+	if err != nil {
+		return err
+	}
+	pkgArgs := *args
+	pkgArgs.Subdir = subdir
+	args = &pkgArgs
+
+	if utils.IsArchiveURL(pkg) {
+		return installArchive(pkg, args)
+	}
+	if utils.IsLocalPath(pkg) {
+		return installLocal(pkg, args)
+	}
+
+	remote, err := utils.ParseRemote(pkg)
+	if err != nil {
+		return err
+	}
+	if args.VCS != "" {
+		if _, err := vcs.Get(args.VCS); err != nil {
+			return err
+		}
+		// git is the default and is recorded without a name
+		remote.VCS = args.VCS
+		if args.VCS == vcs.Default {
+			remote.VCS = ""
+		}
+	}
+	pkg = remote.String()
+
 	fmt.Printf("Installing %s\n", pkg)
 	start := time.Now()
-	repoName := utils.GetRepoName(pkg)
+	repoName := packageName(remote.Name(), subdir)
 	buildDir := filepath.Join("/tmp/argon/builds", repoName)
 
+	track, ref, err := resolveTrack(pkg, args)
+	if err != nil {
+		return err
+	}
+
+	if err := preflight(repoName, "", args.Target, args.Static); err != nil {
+		return err
+	}
+
 	var hash string
-	var err error
+	var dirty bool
+	var patches []string
 
+	if utils.DirectoryExists(buildDir) && !utils.IsDirEmpty(buildDir) && ownedByOthers(buildDir) {
+		// git reads hooks and config from the tree, so never run it in one the build user could change
+		fmt.Printf("%s was handed to the build user, checking out a fresh copy\n", buildDir)
+		if err := os.RemoveAll(buildDir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", buildDir, err)
+		}
+	}
 	if utils.DirectoryExists(buildDir) && !utils.IsDirEmpty(buildDir) {
 		useExisting, err := handleExistingDir(buildDir)
 		if err != nil {
 			return err
 		}
 		if useExisting {
-			hash, err = utils.GetGitHash(buildDir)
+			hash, err = sourceRevision(pkg, buildDir)
 			if err != nil {
-				fmt.Printf("Warning: Could not get git hash: %v\n", err)
+				fmt.Printf("Warning: Could not get revision: %v\n", err)
+			}
+			// an old tree may carry edits the revision doesn't describe
+			if _, dirty, err = vcs.LocalRevision(buildDir); err != nil {
+				fmt.Printf("Warning: could not check %s for local changes: %v\n", buildDir, err)
+				dirty = true
+			}
+			if dirty && requiresSignature(repoName) {
+				return fmt.Errorf("refusing to build %s: %s may have local changes a signature can't vouch for; remove it to build a fresh checkout", repoName, buildDir)
+			}
+			if args.Patches != "" {
+				fmt.Printf("Warning: not applying patches to the existing %s\n", buildDir)
 			}
 			goto build
 		}
 	}
 
-	if err := cloneRepo(pkg, args.Branch, buildDir); err != nil {
+	if err := cloneRepo(pkg, ref, buildDir, subdir); err != nil {
 		return fmt.Errorf("failed to clone: %w", err)
 	}
 
-	hash, err = utils.GetGitHash(buildDir)
+	hash, err = sourceRevision(pkg, buildDir)
 	if err != nil {
-		fmt.Printf("Warning: Could not get git hash: %v\n", err)
+		fmt.Printf("Warning: Could not get revision: %v\n", err)
 	}
 
-	if err := applyPatches(buildDir, args.Patches); err != nil {
-		fmt.Printf("Failed to apply patches: %v\n", err)
+	patches, err = applyPatches(buildDir, args.Patches)
+	if err != nil {
+		return fmt.Errorf("failed to apply patches: %w", err)
 	}
 
 build:
-	buildSystem, err := detectAndBuild(buildDir, repoName, args.Static)
+	signer, err := verifySource(buildDir, repoName, ref)
+	if err != nil {
+		return err
+	}
+
+	submodules, err := vcs.SubmoduleHashes(buildDir)
+	if err != nil {
+		fmt.Printf("Warning: Could not get submodule hashes: %v\n", err)
+	}
+
+	record := utils.Package{
+		Name:       repoName,
+		Repo:       pkg,
+		Hash:       hash,
+		Static:     args.Static,
+		Track:      track.String(),
+		Ref:        ref,
+		Smoke:      smokeTestFromArgs(args),
+		Submodules: submodules,
+		Signer:     signer,
+		Subdir:     subdir,
+		PR:         args.PR,
+		Dirty:      dirty,
+	}
+	if record.Smoke == nil {
+		record.Smoke = installedSmokeTest(repoName)
+	}
+	if err := buildAndInstall(buildDir, args, record, patches); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("Installed in %.2fs\n", elapsed.Seconds())
+	return nil
+}
+
+func packageName(repoName, subdir string) string {
+	if subdir == "" {
+		return repoName
+	}
+	return path.Base(subdir)
+}
+
+// buildAndInstall builds sourceDir, which has patches applied on top of the
+// recorded revision.
+func buildAndInstall(sourceDir string, args *cli.InstallArgs, record utils.Package, patches []string) (err error) {
+	buildDir := filepath.Join(sourceDir, filepath.FromSlash(record.Subdir))
+	if !utils.DirectoryExists(buildDir) {
+		return fmt.Errorf("subdirectory %s not found", record.Subdir)
+	}
+
+	record.Sandbox = record.Sandbox || args.Sandbox
+	if args.Jobs > 0 {
+		record.Jobs = args.Jobs
+	}
+	if args.Target != "" {
+		record.Target = args.Target
+	}
+	b, err := newBuildContext(sourceDir, record, args.Quiet)
+	defer func() {
+		b.close(err)
+	}()
 	if err != nil {
 		return fmt.Errorf("build failed: %w", err)
 	}
+	if err := b.useCompilerCache(record.Name, compilerCacheSetting(record.Name)); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
 
-	if err := installBinary(buildDir, repoName, args.Yes, args.Static); err != nil {
+	selected, err := selectBuild(b, buildDir, args.Yes)
+	if err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+	record.BuildSystem = selected.system
+	if err := b.setTarget(record, selected.system); err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+	record.Build = nil
+	if !b.build.Empty() {
+		record.Build = b.build
+	}
+	if record.Target != "" {
+		record.Target = b.target.String()
+	}
+	reqs, err := requiredTools(selected.system, b.target, b.build, b.requires)
+	if err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+
+	record.CacheKey = buildCacheKey(b, record, selected.system, reqs, patches)
+	var binaryPath string
+	if record.CacheKey != "" && !args.Rebuild {
+		binaryPath = cachedBuild(record.CacheKey, record.Name)
+	}
+	cached := binaryPath != ""
+	var cacheStats []string
+	if cached {
+		b.printf("Using cached build %s\n", record.CacheKey[:12])
+	} else {
+		b.zeroCacheStats()
+		err := runBuild(b, selected, reqs, args.Static, args.Yes)
+		cacheStats = b.cacheStats()
+		if err != nil {
+			return fmt.Errorf("build failed: %w", err)
+		}
+		binaryPath, err = findBinary(selected.dir, record.Name, b.target)
+		if err != nil && selected.dir != buildDir {
+			binaryPath, err = findBinary(buildDir, record.Name, b.target)
+		}
+		if err != nil && record.Subdir != "" {
+			// cargo workspaces put target/ at the repository root
+			binaryPath, err = findBinary(sourceDir, record.Name, b.target)
+		}
+		if err != nil {
+			return fmt.Errorf("installation failed: %w", err)
+		}
+	}
+
+	artifactRoot := sourceDir
+	if cached {
+		artifactRoot = cacheEntryDir(record.CacheKey)
+	}
+	stagedPath, err := stageBinary(binaryPath, artifactRoot, record.Name)
+	if err != nil {
 		return fmt.Errorf("installation failed: %w", err)
 	}
+	defer os.RemoveAll(filepath.Dir(stagedPath))
 
-	if err := addToPackageList(pkg, repoName, buildSystem, hash, args.Static); err != nil {
-		fmt.Printf("Warning: Could not update package list: %v\n", err)
+	smoke := resolveSmokeTest(record)
+	if smoke != nil && b.target.Cross() {
+		fmt.Printf("Skipping smoke test: built for %s\n", b.target)
+		smoke = nil
+	}
+	if err := smokeCheck(b, stagedPath, smoke); err != nil {
+		return fmt.Errorf("smoke check failed: %w", err)
 	}
 
-	elapsed := time.Since(start)
-	fmt.Printf("Installed in %.2fs\n", elapsed.Seconds())
+	if err := installBinary(stagedPath, record.Name); err != nil {
+		return fmt.Errorf("installation failed: %w", err)
+	}
+	if !cached && record.CacheKey != "" {
+		if err := storeBuild(record.CacheKey, stagedPath, record); err != nil {
+			fmt.Printf("Warning: could not cache build: %v\n", err)
+		}
+	}
+
+	if err := addToPackageList(record); err != nil {
+		fmt.Printf("Warning: Could not update package list: %v\n", err)
+	}
+	if len(cacheStats) > 0 {
+		fmt.Printf("Compiler cache: %s\n", strings.Join(cacheStats, ", "))
+	}
 	return nil
 }
 
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 	"argon-go/utils"
 )
 
@@ -17,10 +18,22 @@ func List() {
 		if pkg.Static {
 			staticFlag = " [static]"
 		}
+		if pkg.Source == utils.SourceLocal || pkg.Source == utils.SourceArchive {
+			staticFlag += " [" + pkg.Source + "]"
+		}
+		if pkg.PR != 0 {
+			staticFlag += fmt.Sprintf(" [pr #%d]", pkg.PR)
+		}
 		hash := pkg.Hash
+		if pkg.Source == utils.SourceArchive {
+			hash = strings.TrimPrefix(pkg.Digest, "sha256:")
+		}
 		if len(hash) > 8 {
 			hash = hash[:8]
 		}
+		if pkg.Dirty {
+			hash += "+dirty"
+		}
 		fmt.Printf("  %-25s  %-15s  %s%s\n", pkg.Name, pkg.BuildSystem, hash, staticFlag)
 	}
 }
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"argon-go/cli"
+	"argon-go/utils"
+	"argon-go/vcs"
+)
+
+func snapshotLocal(srcDir, repoName string) (string, error) {
+	snapshotDir, err := os.MkdirTemp(filepath.Join(utils.ArgonTempDir, "builds"), repoName+"-local-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build workspace: %w", err)
+	}
+	cmd := exec.Command("cp", "-a", srcDir+"/.", snapshotDir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(snapshotDir)
+		return "", fmt.Errorf("failed to snapshot %s: %w", srcDir, err)
+	}
+	return snapshotDir, nil
+}
+
+func buildLocal(record utils.Package, args *cli.InstallArgs) error {
+	buildDir := record.Repo
+	var patches []string
+	if !record.InPlace {
+		snapshotDir, err := snapshotLocal(record.Repo, record.Name)
+		if err != nil {
+			return err
+		}
+		buildDir = snapshotDir
+		patches, err = applyPatches(buildDir, args.Patches)
+		if err != nil {
+			os.RemoveAll(buildDir)
+			return fmt.Errorf("failed to apply patches: %w", err)
+		}
+	}
+
+	if err := buildAndInstall(buildDir, args, record, patches); err != nil {
+		if !record.InPlace {
+			fmt.Printf("Build workspace left at %s\n", buildDir)
+		}
+		return err
+	}
+
+	if !record.InPlace {
+		os.RemoveAll(buildDir)
+	}
+	return nil
+}
+
+func installLocal(spec string, args *cli.InstallArgs) error {
+	srcDir, err := filepath.Abs(spec)
+	if err != nil {
+		return err
+	}
+	if !utils.DirectoryExists(srcDir) {
+		return fmt.Errorf("local source %s is not a directory", srcDir)
+	}
+
+	fmt.Printf("Installing %s (local)\n", srcDir)
+	start := time.Now()
+	repoName := packageName(filepath.Base(srcDir), args.Subdir)
+
+	hash, dirty, _ := vcs.LocalRevision(srcDir)
+	if dirty {
+		fmt.Println("Warning: working tree has uncommitted changes")
+	}
+
+	record := utils.Package{
+		Name:    repoName,
+		Repo:    srcDir,
+		Source:  utils.SourceLocal,
+		Hash:    hash,
+		Dirty:   dirty,
+		InPlace: args.InPlace,
+		Static:  args.Static,
+		Smoke:   smokeTestFromArgs(args),
+		Subdir:  args.Subdir,
+	}
+	if record.Smoke == nil {
+		record.Smoke = installedSmokeTest(repoName)
+	}
+
+	if err := buildLocal(record, args); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("Installed in %.2fs\n", elapsed.Seconds())
+	return nil
+}
+
+func upgradeLocal(pkg utils.Package, args *cli.UpgradeArgs) error {
+	if !utils.DirectoryExists(pkg.Repo) {
+		return fmt.Errorf("local source %s no longer exists", pkg.Repo)
+	}
+
+	record := pkg
+	record.Hash, record.Dirty, _ = vcs.LocalRevision(pkg.Repo)
+
+	installArgs := &cli.InstallArgs{
+		Packages: []string{pkg.Repo},
+		Yes:      args.Yes,
+		Static:   pkg.Static,
+		InPlace:  pkg.InPlace,
+		Quiet:    args.Quiet,
+	}
+	return buildLocal(record, installArgs)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"argon-go/utils"
+	"argon-go/vcs"
 )
 
 func Remove(packageName string) {
@@ -53,6 +54,12 @@ func Remove(packageName string) {
 			fmt.Printf("Removed build directory: %s\n", buildDir)
 		}
 	}
+	if pkgToRemove.Source != utils.SourceLocal && pkgToRemove.Source != utils.SourceArchive {
+		backend, remote, err := sourceBackend(pkgToRemove.Repo)
+		if err == nil {
+			vcs.Prune(backend, utils.SourceCachePath(remote))
+		}
+	}
 	
 	if err := utils.SaveInstalledPackages(updatedPackages); err != nil {
 		fmt.Printf("Error updating package list: %v\n", err)
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	statusUpToDate = "up-to-date"
+	statusUpgraded = "upgraded"
+	statusFailed   = "failed"
+)
+
+type upgradeResult struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	OldHash  string  `json:"old_hash"`
+	NewHash  string  `json:"new_hash,omitempty"`
+	OldRef   string  `json:"old_ref,omitempty"`
+	NewRef   string  `json:"new_ref,omitempty"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type upgradeReport struct {
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished"`
+	Packages []upgradeResult `json:"packages"`
+}
+
+func (r upgradeReport) counts() (upgraded, failed int) {
+	for _, result := range r.Packages {
+		switch result.Status {
+		case statusUpgraded:
+			upgraded++
+		case statusFailed:
+			failed++
+		}
+	}
+	return upgraded, failed
+}
+
+type junitSuite struct {
+	XMLName   xml.Name    `xml:"testsuite"`
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func reportFormat(path, format string) (string, error) {
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(path), ".xml") {
+			return "junit", nil
+		}
+		return "json", nil
+	}
+	switch format {
+	case "json", "junit":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown report format %q (want json or junit)", format)
+}
+
+func writeReport(path, format string, report upgradeReport) error {
+	format, err := reportFormat(path, format)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if format == "junit" {
+		data, err = junitReport(report)
+	} else {
+		data, err = json.MarshalIndent(report, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func junitReport(report upgradeReport) ([]byte, error) {
+	_, failed := report.counts()
+	suite := junitSuite{
+		Name:      "argon upgrade",
+		Tests:     len(report.Packages),
+		Failures:  failed,
+		Time:      report.Finished.Sub(report.Started).Seconds(),
+		Timestamp: report.Started.Format(time.RFC3339),
+	}
+	for _, result := range report.Packages {
+		tc := junitCase{
+			Name:      result.Name,
+			Classname: "argon.upgrade",
+			Time:      result.Duration,
+			SystemOut: fmt.Sprintf("status=%s old=%s new=%s", result.Status, result.OldHash, result.NewHash),
+		}
+		if result.Status == statusFailed {
+			tc.Failure = &junitFailure{Message: result.Error, Text: result.Error}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
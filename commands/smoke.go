@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"argon-go/cli"
+	"argon-go/config"
+	"argon-go/utils"
+)
+
+const smokeTimeout = 30 * time.Second
+
+func smokeTestFromArgs(args *cli.InstallArgs) *utils.SmokeTest {
+	if args.Smoke == "" && args.SmokeExpect == "" {
+		return nil
+	}
+	test := &utils.SmokeTest{
+		Args:   strings.Fields(args.Smoke),
+		Expect: args.SmokeExpect,
+	}
+	return withDefaultArgs(test)
+}
+
+// withDefaultArgs runs --version when a test gives no arguments, as a bare
+// daemon would only run into the timeout.
+func withDefaultArgs(test *utils.SmokeTest) *utils.SmokeTest {
+	if test == nil || len(test.Args) > 0 {
+		return test
+	}
+	withArgs := *test
+	withArgs.Args = []string{"--version"}
+	return &withArgs
+}
+
+func resolveSmokeTest(record utils.Package) *utils.SmokeTest {
+	if record.Smoke != nil {
+		return record.Smoke
+	}
+	recipe, err := config.LoadRecipe(record.Name)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	return withDefaultArgs(recipe.Smoke)
+}
+
+// stageBinary copies the binary out of root, the tree it was built in.
+func stageBinary(binaryPath, root, repoName string) (string, error) {
+	stagingDir := filepath.Join(utils.ArgonTempDir, "staging", repoName)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return "", err
+	}
+	f, err := openArtifact(binaryPath, root)
+	if err != nil {
+		return "", fmt.Errorf("failed to read binary: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read binary: %w", err)
+	}
+	stagedPath := filepath.Join(stagingDir, repoName)
+	if err := os.WriteFile(stagedPath, data, 0755); err != nil {
+		return "", fmt.Errorf("failed to stage binary: %w", err)
+	}
+	return stagedPath, nil
+}
+
+// openArtifact opens a file the build user produced. The build tree belongs
+// to that user, so root must not follow a symlink out of it to a file the
+// build could not read itself.
+func openArtifact(path, root string) (*os.File, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	// check what was actually opened, a directory on the way may have been
+	// swapped for a symlink since the Lstat
+	opened, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Fd()))
+	if err == nil && opened != realRoot && !strings.HasPrefix(opened, realRoot+"/") {
+		err = fmt.Errorf("%s resolves outside %s", path, root)
+	}
+	if err == nil {
+		info, err = f.Stat()
+		if err == nil && !info.Mode().IsRegular() {
+			err = fmt.Errorf("%s is not a regular file", path)
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func smokeCheck(b *buildContext, binaryPath string, test *utils.SmokeTest) error {
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", binaryPath)
+	}
+	if test == nil {
+		return nil
+	}
+
+	var expect *regexp.Regexp
+	if test.Expect != "" {
+		expect, err = regexp.Compile(test.Expect)
+		if err != nil {
+			return fmt.Errorf("invalid smoke test pattern: %w", err)
+		}
+	}
+
+	// a sandboxed build's artifact runs in the same sandbox it was built in
+	dir := filepath.Dir(binaryPath)
+	if b.sandbox != nil {
+		b.writable = append(b.writable, dir)
+	}
+	var output bytes.Buffer
+	cmd := b.command(dir, binaryPath, test.Args...)
+	cmd.Stdout, cmd.Stderr = &output, &output
+	// don't wait on children that keep the output open
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(smokeTimeout, func() { cmd.Process.Kill() })
+	err = cmd.Wait()
+	timedOut := !timer.Stop()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	if timedOut {
+		return fmt.Errorf("%s timed out after %s", filepath.Base(binaryPath), smokeTimeout)
+	}
+	if exitCode != test.ExitCode {
+		return fmt.Errorf("exit code %d, expected %d: %s", exitCode, test.ExitCode, strings.TrimSpace(output.String()))
+	}
+	if expect != nil && !expect.Match(output.Bytes()) {
+		return fmt.Errorf("output does not match %q: %s", test.Expect, strings.TrimSpace(output.String()))
+	}
+
+	fmt.Printf("Smoke test passed: %s %s\n", filepath.Base(binaryPath), strings.Join(test.Args, " "))
+	return nil
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"argon-go/config"
+	"argon-go/utils"
+)
+
+// buildSystems maps the build files argon knows to the build system that
+// handles them.
+var buildSystems = map[string]string{
+	"Makefile":       "make",
+	"makefile":       "make",
+	"Cargo.toml":     "cargo",
+	"CMakeLists.txt": "cmake",
+	"configure":      "configure",
+	"configure.ac":   "autotools",
+	"configure.in":   "autotools",
+	"build.zig":      "zig",
+	"build.sh":       "shell",
+}
+
+// toolchains lists what each build system runs; "cc" stands for the C
+// compiler the build environment picks.
+var toolchains = map[string][]utils.Requirement{
+	"make":      {{Command: "make"}},
+	"cargo":     {{Command: "cargo"}},
+	"cmake":     {{Command: "cmake", Constraint: ">=3.12"}, {Command: "cc"}}, // cmake --build --parallel
+	"configure": {{Command: "sh"}, {Command: "make"}, {Command: "cc"}},
+	"autotools": {{Command: "autoreconf"}, {Command: "automake"}, {Command: "sh"}, {Command: "make"}, {Command: "cc"}},
+	"zig":       {{Command: "zig", Constraint: ">=0.11"}}, // zig build -j and --fetch
+	"shell":     {{Command: "sh"}},
+}
+
+// packageManagers are tried in order to phrase install hints.
+var packageManagers = []struct {
+	command string
+	install string
+}{
+	{"apt-get", "apt-get install"},
+	{"dnf", "dnf install"},
+	{"pacman", "pacman -S"},
+	{"apk", "apk add"},
+	{"zypper", "zypper install"},
+	{"xbps-install", "xbps-install"},
+}
+
+// toolPackages names the package providing a tool where it differs from the
+// command, per package manager.
+var toolPackages = map[string]map[string]string{
+	"cc":         {"": "gcc"},
+	"autoreconf": {"": "autoconf"},
+	"c++":        {"": "g++"},
+	"gcc":        {"": "gcc"},
+	"g++":        {"apt-get": "g++", "dnf": "gcc-c++", "pacman": "gcc", "apk": "g++", "zypper": "gcc-c++", "xbps-install": "gcc"},
+	"ninja":      {"apt-get": "ninja-build", "dnf": "ninja-build", "": "ninja"},
+}
+
+// manualHints cover tools usually not installed from the distribution.
+var manualHints = map[string]string{
+	"cargo":  "install Rust from https://rustup.rs",
+	"rustup": "install Rust from https://rustup.rs",
+	"zig":    "download Zig from https://ziglang.org/download",
+}
+
+// requiredTools resolves the toolchain of a build system for a target and
+// build environment, plus the extra requirements from a recipe.
+func requiredTools(system string, target utils.Target, build *utils.BuildEnv, extra []string) ([]utils.Requirement, error) {
+	env := map[string]string{}
+	if build != nil {
+		for key, value := range build.Env {
+			env[key] = value
+		}
+	}
+	if env["CC"] == "" {
+		env["CC"] = os.Getenv("CC")
+	}
+
+	var reqs []utils.Requirement
+	for _, req := range toolchains[system] {
+		if req.Command != "cc" {
+			reqs = append(reqs, req)
+			continue
+		}
+		for _, command := range commandWords(env["CC"], "cc") {
+			reqs = append(reqs, utils.Requirement{Command: command})
+		}
+	}
+
+	switch system {
+	case "cmake":
+		if build != nil && strings.Contains(strings.Join(build.CMakeArgs, " "), "Ninja") {
+			reqs = append(reqs, utils.Requirement{Command: "ninja"})
+		} else {
+			reqs = append(reqs, utils.Requirement{Command: "make"})
+		}
+	case "cargo":
+		if !target.Native() {
+			reqs = append(reqs, utils.Requirement{Command: "rustup"})
+		}
+		if target.Cross() {
+			linker := "CARGO_TARGET_" + strings.ToUpper(strings.ReplaceAll(target.Rust(), "-", "_")) + "_LINKER"
+			for _, command := range commandWords(env[linker], "") {
+				reqs = append(reqs, utils.Requirement{Command: command})
+			}
+		}
+	}
+
+	for _, s := range extra {
+		req, err := utils.ParseRequirement(s)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// commandWords picks the programs out of a variable like CC="ccache gcc -m32".
+func commandWords(value, fallback string) []string {
+	var words []string
+	for _, word := range strings.Fields(value) {
+		if !strings.HasPrefix(word, "-") && !strings.Contains(word, "=") {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 && fallback != "" {
+		words = []string{fallback}
+	}
+	return words
+}
+
+// checkToolchain reports every missing or outdated tool at once.
+func checkToolchain(system string, reqs []utils.Requirement) error {
+	var problems []string
+	seen := map[string]bool{}
+	for _, req := range reqs {
+		if seen[req.String()] {
+			continue
+		}
+		seen[req.String()] = true
+		if err := req.Check(); err != nil {
+			problems = append(problems, fmt.Sprintf("  %s: %v (%s)", req, err, installHint(req.Command)))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("missing build tools for %s:\n%s", system, strings.Join(problems, "\n"))
+}
+
+func installHint(command string) string {
+	if hint, ok := manualHints[command]; ok {
+		return hint
+	}
+	// cross compilers like aarch64-linux-gnu-gcc
+	if strings.Count(command, "-") >= 2 && (strings.HasSuffix(command, "-gcc") || strings.HasSuffix(command, "-g++")) {
+		triple := command[:strings.LastIndex(command, "-")]
+		return fmt.Sprintf("install a cross compiler for %s, e.g. gcc-%s on Debian", triple, triple)
+	}
+	for _, pm := range packageManagers {
+		if _, err := exec.LookPath(pm.command); err != nil {
+			continue
+		}
+		pkg := command
+		if names, ok := toolPackages[command]; ok {
+			if name, ok := names[pm.command]; ok {
+				pkg = name
+			} else if name, ok := names[""]; ok {
+				pkg = name
+			}
+		}
+		return "try: " + pm.install + " " + pkg
+	}
+	return "install " + command + " with your package manager"
+}
+
+// preflight checks the toolchain before anything is downloaded, which is
+// possible when the build system is already known from a recipe or an
+// earlier install.
+func preflight(name, system string, targetSpec string, static bool) error {
+	recipe, err := config.LoadRecipe(name)
+	if err != nil {
+		return err
+	}
+	if recipe.BuildSystem != "" {
+		system = recipe.BuildSystem
+	}
+	if system == "" && len(recipe.Requires) == 0 {
+		return nil
+	}
+	if _, ok := toolchains[system]; system != "" && !ok {
+		return fmt.Errorf("recipe for %s: unknown build system %q", name, system)
+	}
+
+	target, err := utils.ResolveTarget(targetSpec, static && buildsForLibc(system))
+	if err != nil {
+		return err
+	}
+	cfg, _ := config.Load()
+	build := utils.MergeBuildEnv(&utils.BuildEnv{Env: target.CrossEnv()}, cfg.Build, recipe.Build)
+	reqs, err := requiredTools(system, target, build, recipe.Requires)
+	if err != nil {
+		return fmt.Errorf("recipe for %s: %w", name, err)
+	}
+	if system == "" {
+		system = name
+	}
+	return checkToolchain(system, reqs)
+}
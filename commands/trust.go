@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"fmt"
+
+	"argon-go/config"
+	"argon-go/utils"
+	"argon-go/vcs"
+)
+
+// requiresSignature reports whether a recipe makes argon verify the source.
+func requiresSignature(name string) bool {
+	recipe, err := config.LoadRecipe(name)
+	return err != nil || (recipe.Trust != nil && recipe.Trust.Verify != "")
+}
+
+func verifySource(buildDir, name, ref string) (string, error) {
+	recipe, err := config.LoadRecipe(name)
+	if err != nil {
+		return "", err
+	}
+	if recipe.Trust == nil || recipe.Trust.Verify == "" {
+		return "", nil
+	}
+
+	if _, ok := vcs.Detect(buildDir).(vcs.Git); !ok {
+		return "", fmt.Errorf("refusing to build %s: signature verification is only supported for git sources", name)
+	}
+
+	signer, err := utils.VerifySignature(buildDir, ref, *recipe.Trust)
+	if err != nil {
+		return "", fmt.Errorf("refusing to build %s: %w", name, err)
+	}
+	fmt.Printf("Verified %s signature by %s\n", recipe.Trust.Verify, signer)
+	return signer, nil
+}
@@ -1,31 +1,116 @@
 package commands
 
 import (
-	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 	"argon-go/cli"
 	"argon-go/utils"
+	"argon-go/vcs"
 )
 
-func checkForUpdate(pkg utils.Package) (bool, string, error) {
+const (
+	ExitNothingToDo = 0
+	ExitFailures    = 1
+	ExitUpgraded    = 100
+)
+
+func checkForUpdate(pkg utils.Package) (bool, string, string, error) {
+	if pkg.Source == utils.SourceArchive {
+		return false, "", "", nil
+	}
+	if pkg.Source == utils.SourceLocal {
+		if !utils.DirectoryExists(pkg.Repo) {
+			return false, "", "", fmt.Errorf("local source %s no longer exists", pkg.Repo)
+		}
+		hash, dirty, _ := vcs.LocalRevision(pkg.Repo)
+		return hash == "" || hash != pkg.Hash || dirty || pkg.Dirty, hash, "", nil
+	}
+	
+	track, err := utils.ParseTrack(pkg.Track)
+	if err != nil {
+		return false, "", "", err
+	}
 	currentHash := pkg.Hash
-	remoteHash, err := utils.GetRemoteHash(pkg.Repo, "")
+	remoteHash, ref, err := remoteHash(pkg.Repo, track)
 	if err != nil {
-		return false, "", err
+		return false, "", "", err
 	}
-	return remoteHash != currentHash, remoteHash, nil
+	if remoteHash == currentHash && submodulesChanged(pkg, remoteHash) {
+		fmt.Printf("%s: submodules differ from the pins of the installed commit\n", pkg.Name)
+		return true, remoteHash, ref, nil
+	}
+	return remoteHash != currentHash, remoteHash, ref, nil
 }
 
-func upgradePackage(pkg utils.Package, yes bool) {
-	hasUpdate, newHash, err := checkForUpdate(pkg)
+// submodulesChanged catches a build whose submodules were moved off the
+// commits rev pins, e.g. in a reused build directory, so upgrade rebuilds
+// it from a clean checkout.
+func submodulesChanged(pkg utils.Package, rev string) bool {
+	remote, err := utils.ParseRemote(pkg.Repo)
+	if err != nil || remote.VCS != "" {
+		return false
+	}
+	pins, err := vcs.SubmodulePins(utils.SourceCachePath(remote), rev)
+	if err != nil {
+		return false
+	}
+	return pinsDiffer(pkg.Submodules, pins)
+}
+
+// pinsDiffer compares the submodules recorded for a build, nested ones
+// included, with the top-level pins of its commit.
+func pinsDiffer(recorded, pins map[string]string) bool {
+	for path, pin := range pins {
+		if recorded[path] != pin {
+			return true
+		}
+	}
+	for path := range recorded {
+		if _, ok := pins[path]; ok {
+			continue
+		}
+		nested := false
+		for parent := range pins {
+			if strings.HasPrefix(path, parent+"/") {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			return true
+		}
+	}
+	return false
+}
+
+func upgradePackage(pkg utils.Package, args *cli.UpgradeArgs) (result upgradeResult) {
+	start := time.Now()
+	result = upgradeResult{
+		Name:    pkg.Name,
+		Status:  statusUpToDate,
+		OldHash: pkg.Hash,
+		OldRef:  pkg.Ref,
+	}
+	defer func() {
+		result.Duration = time.Since(start).Seconds()
+	}()
+	
+	hasUpdate, newHash, newRef, err := checkForUpdate(pkg)
 	if err != nil {
 		fmt.Printf("Error checking updates for %s: %v\n", pkg.Name, err)
-		return
+		result.Status = statusFailed
+		result.Error = fmt.Sprintf("checking updates: %v", err)
+		return result
 	}
 	if !hasUpdate {
 		fmt.Printf("%s is already up to date\n", pkg.Name)
-		return
+		return result
 	}
+	result.NewHash = newHash
+	result.NewRef = newRef
 	
 	oldHash := pkg.Hash
 	if len(oldHash) > 8 {
@@ -36,38 +121,133 @@ func upgradePackage(pkg utils.Package, yes bool) {
 		newHashShort = newHashShort[:8]
 	}
 	
-	fmt.Printf("Updating %s (%s -> %s)\n", pkg.Name, oldHash, newHashShort)
+	from, to := oldHash, newHashShort
+	if pkg.Ref != "" && newRef != "" && pkg.Ref != newRef {
+		from, to = pkg.Ref, newRef
+	}
+	
+	fmt.Printf("Updating %s (%s -> %s)\n", pkg.Name, from, to)
+	
+	upgrade := func() error {
+		return upgradeInWorkspace(pkg, newHash, newRef, args)
+	}
+	if pkg.Source == utils.SourceLocal {
+		upgrade = func() error {
+			return upgradeLocal(pkg, args)
+		}
+	}
+	
+	if err := upgrade(); err != nil {
+		fmt.Printf("Failed to upgrade %s: %v (kept %s)\n", pkg.Name, err, oldHash)
+		result.Status = statusFailed
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = statusUpgraded
+	return result
+}
+
+func upgradeInWorkspace(pkg utils.Package, newHash, newRef string, args *cli.UpgradeArgs) error {
+	backend, remote, err := sourceBackend(pkg.Repo)
+	if err != nil {
+		return err
+	}
+	mirrorDir := utils.SourceCachePath(remote)
+	
+	if err := preflight(pkg.Name, pkg.BuildSystem, pkg.Target, pkg.Static); err != nil {
+		return err
+	}
+	
+	workspace, err := os.MkdirTemp(filepath.Join(utils.ArgonTempDir, "builds"), pkg.Name+"-upgrade-")
+	if err != nil {
+		return fmt.Errorf("failed to create build workspace: %w", err)
+	}
+	
+	// refs outside branches and tags are only in the cache once fetched by name
+	rev := newHash
+	if strings.HasPrefix(newRef, "refs/") {
+		rev = newRef
+	}
+	if err := cloneRepo(pkg.Repo, rev, workspace, pkg.Subdir); err != nil {
+		os.RemoveAll(workspace)
+		vcs.Prune(backend, mirrorDir)
+		return fmt.Errorf("failed to clone: %w", err)
+	}
+	
+	signer, err := verifySource(workspace, pkg.Name, newRef)
+	if err != nil {
+		os.RemoveAll(workspace)
+		vcs.Prune(backend, mirrorDir)
+		return err
+	}
+	
+	record := pkg
+	record.Hash = newHash
+	record.Ref = newRef
+	record.Signer = signer
+	if hash, err := backend.Revision(workspace); err == nil {
+		record.Hash = hash
+	}
+	record.Submodules, err = vcs.SubmoduleHashes(workspace)
+	if err != nil {
+		fmt.Printf("Warning: Could not get submodule hashes: %v\n", err)
+	}
 	
 	installArgs := &cli.InstallArgs{
 		Packages: []string{pkg.Repo},
-		Yes:      yes,
+		Yes:      args.Yes,
 		Static:   pkg.Static,
+		Track:    pkg.Track,
+		Quiet:    args.Quiet,
 	}
 	
-	ctx := context.Background()
-	if err := installSingle(ctx, pkg.Repo, installArgs); err != nil {
-		fmt.Printf("Failed to upgrade %s: %v\n", pkg.Name, err)
+	if err := buildAndInstall(workspace, installArgs, record, nil); err != nil {
+		fmt.Printf("Build workspace left at %s\n", workspace)
+		return err
 	}
+	
+	os.RemoveAll(workspace)
+	vcs.Prune(backend, mirrorDir)
+	return nil
 }
 
-func HandleUpgrade(args *cli.UpgradeArgs) {
+func HandleUpgrade(args *cli.UpgradeArgs) int {
 	packages := utils.GetInstalledPackages()
 	if len(packages) == 0 {
 		fmt.Println("No packages installed")
-		return
+		return ExitNothingToDo
 	}
 	
 	toUpgrade := packages
 	
 	if len(toUpgrade) == 0 {
 		fmt.Println("No packages to upgrade")
-		return
+		return ExitNothingToDo
 	}
 	
+	report := upgradeReport{Started: time.Now()}
 	fmt.Printf("Found %d packages to upgrade\n", len(toUpgrade))
 	for i, pkg := range toUpgrade {
 		fmt.Printf("\n[%d/%d] ", i+1, len(toUpgrade))
-		upgradePackage(pkg, args.Yes)
+		report.Packages = append(report.Packages, upgradePackage(pkg, args))
+	}
+	report.Finished = time.Now()
+	
+	if args.Report != "" {
+		if err := writeReport(args.Report, args.ReportFormat, report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+			return ExitFailures
+		}
+		fmt.Printf("Report written to %s\n", args.Report)
+	}
+	
+	upgraded, failed := report.counts()
+	fmt.Printf("\nUpgrade complete: %d upgraded, %d failed, %d up to date\n", upgraded, failed, len(report.Packages)-upgraded-failed)
+	switch {
+	case failed > 0:
+		return ExitFailures
+	case upgraded > 0:
+		return ExitUpgraded
 	}
-	fmt.Println("\nUpgrade complete")
+	return ExitNothingToDo
 }
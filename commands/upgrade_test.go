@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"argon-go/vcs"
+)
+
+const (
+	libPin    = "1111111111111111111111111111111111111111"
+	vendorPin = "2222222222222222222222222222222222222222"
+)
+
+// pinnedMirror creates a bare mirror whose HEAD pins lib and vendor/zlib.
+func pinnedMirror(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(cmd.Environ(),
+			"GIT_AUTHOR_NAME=argon", "GIT_AUTHOR_EMAIL=argon@localhost",
+			"GIT_COMMITTER_NAME=argon", "GIT_COMMITTER_EMAIL=argon@localhost",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(dir, "init", "-q", work)
+	git(work, "update-index", "--add", "--cacheinfo", "160000,"+libPin+",lib")
+	git(work, "update-index", "--add", "--cacheinfo", "160000,"+vendorPin+",vendor/zlib")
+	git(work, "commit", "-q", "-m", "pin submodules")
+	mirror := filepath.Join(dir, "mirror.git")
+	git(dir, "clone", "-q", "--bare", work, mirror)
+	return mirror, git(work, "rev-parse", "HEAD")
+}
+
+func TestSubmodulePinsChanged(t *testing.T) {
+	mirror, rev := pinnedMirror(t)
+	pins, err := vcs.SubmodulePins(mirror, rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 || pins["lib"] != libPin || pins["vendor/zlib"] != vendorPin {
+		t.Fatalf("SubmodulePins = %v", pins)
+	}
+
+	tests := []struct {
+		name     string
+		recorded map[string]string
+		changed  bool
+	}{
+		{"same pins", map[string]string{"lib": libPin, "vendor/zlib": vendorPin}, false},
+		{"nested submodule", map[string]string{"lib": libPin, "lib/deps/x": "3333", "vendor/zlib": vendorPin}, false},
+		{"bumped in place", map[string]string{"lib": "4444", "vendor/zlib": vendorPin}, true},
+		{"not checked out", map[string]string{"lib": libPin}, true},
+		{"removed upstream", map[string]string{"lib": libPin, "vendor/zlib": vendorPin, "old": "5555"}, true},
+	}
+	for _, tt := range tests {
+		if got := pinsDiffer(tt.recorded, pins); got != tt.changed {
+			t.Errorf("%s: pinsDiffer = %t, want %t", tt.name, got, tt.changed)
+		}
+	}
+}
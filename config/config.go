@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"argon-go/utils"
+)
+
+const (
+	ConfigDir  = "/etc/argon"
+	ConfigPath = ConfigDir + "/config.json"
+	RecipesDir = ConfigDir + "/recipes"
+)
+
+type Config struct {
+	Aliases  map[string]string   `json:"aliases,omitempty"`
+	Rewrites map[string]string   `json:"rewrites,omitempty"`
+	Mirrors  map[string][]string `json:"mirrors,omitempty"`
+	// BuildUser runs builds; "root" disables dropping privileges.
+	BuildUser string `json:"build_user,omitempty"`
+	// Sandbox isolates every build, not just those installed with --sandbox.
+	Sandbox bool `json:"sandbox,omitempty"`
+	// KeepLogs is how many build logs to keep per package.
+	KeepLogs int `json:"keep_logs,omitempty"`
+	// Jobs is the default build parallelism, one per CPU when unset.
+	Jobs int `json:"jobs,omitempty"`
+	// Build sets compilers, flags and extra make/cmake arguments for all packages.
+	Build *utils.BuildEnv `json:"build,omitempty"`
+	// CompilerCache is ccache, sccache or off; by default whichever is installed.
+	CompilerCache string `json:"compiler_cache,omitempty"`
+}
+
+var loaded *Config
+
+func Load() (Config, error) {
+	if loaded != nil {
+		return *loaded, nil
+	}
+	var cfg Config
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid config %s: %w", ConfigPath, err)
+		}
+	}
+	loaded = &cfg
+	return cfg, nil
+}
+
+func Apply() error {
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	utils.SetRemoteAliases(cfg.Aliases)
+	utils.SetRemoteRewrites(cfg.Rewrites, cfg.Mirrors)
+	return nil
+}
+
+type Recipe struct {
+	Smoke   *utils.SmokeTest   `json:"smoke,omitempty"`
+	Trust   *utils.TrustPolicy `json:"trust,omitempty"`
+	Sandbox *SandboxPolicy     `json:"sandbox,omitempty"`
+	Jobs    int                `json:"jobs,omitempty"`
+	Build   *utils.BuildEnv    `json:"build,omitempty"`
+	// BuildSystem and Requires let argon check the toolchain before cloning.
+	BuildSystem string   `json:"build_system,omitempty"`
+	Requires    []string `json:"requires,omitempty"`
+	// CompilerCache overrides the global setting, e.g. "off" for a build it breaks.
+	CompilerCache string `json:"compiler_cache,omitempty"`
+}
+
+// SandboxPolicy relaxes the build sandbox for one package.
+type SandboxPolicy struct {
+	// Network leaves the build in the host network namespace.
+	Network bool `json:"network,omitempty"`
+	// Prefetch runs with network access before the build, replacing the
+	// build system's default (cargo fetch, zig build --fetch).
+	Prefetch []string `json:"prefetch,omitempty"`
+}
+
+func LoadRecipe(name string) (Recipe, error) {
+	var recipe Recipe
+	path := filepath.Join(RecipesDir, name+".json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return recipe, nil
+	}
+	if err != nil {
+		return recipe, err
+	}
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return recipe, fmt.Errorf("invalid recipe %s: %w", path, err)
+	}
+	return recipe, nil
+}
@@ -10,6 +10,8 @@ import (
 
 	"argon-go/cli"
 	"argon-go/commands"
+	"argon-go/config"
+	"argon-go/sandbox"
 	"argon-go/utils"
 )
 
@@ -26,7 +28,14 @@ func requireRoot() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Command {
+		sandbox.Main(os.Args[2:])
+	}
+
 	utils.SetupArgonDirs()
+	if err := config.Apply(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 	
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -58,7 +67,12 @@ func main() {
 		commands.Help(os.Args)
 	case cli.CommandUpgrade:
 		requireRoot()
-		commands.HandleUpgrade(&args.UpgradeArgs)
+		os.Exit(commands.HandleUpgrade(&args.UpgradeArgs))
+	case cli.CommandLog:
+		os.Exit(commands.ShowLog(args.LogArgs.Package, args.LogArgs.Last, args.LogArgs.Failed))
+	case cli.CommandCache:
+		requireRoot()
+		commands.HandleCache(&args.CacheArgs)
 	default:
 		fmt.Println("Usage: argon <command> [options]")
 		fmt.Println("Commands:")
@@ -67,6 +81,8 @@ func main() {
 		fmt.Println("  remove <package>              Remove a package (requires sudo)")
 		fmt.Println("  search <query>                Search for packages")
 		fmt.Println("  upgrade                       Upgrade installed packages (requires sudo)")
+		fmt.Println("  log <package>                 Show build logs")
+		fmt.Println("  cache prune [--all]           Remove unused cached builds (requires sudo)")
 		fmt.Println("  help                          Display this help message")
 		os.Exit(1)
 	}
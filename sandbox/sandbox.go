@@ -0,0 +1,208 @@
+package sandbox
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Command is the hidden argv[1] argon re-executes itself with to set up the
+// sandbox from inside the new namespaces.
+const Command = "__sandbox"
+
+const (
+	capSysAdmin       = 21
+	prCapAmbient      = 47
+	prCapAmbientClear = 4
+	prSetNoNewPrivs   = 38
+	exitSetupFailed   = 125
+)
+
+// Wrap returns a copy of cmd that runs in fresh user and mount namespaces as
+// uid/gid, with everything but writable mounted read-only and, unless network
+// is set, in an empty network namespace.
+func Wrap(cmd *exec.Cmd, uid, gid uint32, writable []string, network bool) *exec.Cmd {
+	args := []string{Command}
+	for _, dir := range writable {
+		args = append(args, "--rw", dir)
+	}
+	args = append(args, "--")
+	args = append(args, cmd.Args...)
+
+	wrapped := exec.Command("/proc/self/exe", args...)
+	wrapped.Dir = cmd.Dir
+	wrapped.Env = cmd.Env
+	wrapped.Stdin = cmd.Stdin
+	wrapped.Stdout = cmd.Stdout
+	wrapped.Stderr = cmd.Stderr
+
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS)
+	if !network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	wrapped.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 flags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: int(uid), HostID: int(uid), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: int(gid), HostID: int(gid), Size: 1}},
+		GidMappingsEnableSetgroups: true,
+		Credential:                 &syscall.Credential{Uid: uid, Gid: gid, Groups: []uint32{}},
+		// only the helper needs it, it is dropped again before the build runs
+		AmbientCaps: []uintptr{capSysAdmin},
+	}
+	return wrapped
+}
+
+// Main is the helper side of Wrap. It never returns.
+func Main(args []string) {
+	var writable []string
+	for len(args) > 0 && args[0] != "--" {
+		if args[0] == "--rw" && len(args) > 1 {
+			writable = append(writable, filepath.Clean(args[1]))
+			args = args[2:]
+			continue
+		}
+		fail(fmt.Errorf("unexpected argument %q", args[0]))
+	}
+	if len(args) < 2 {
+		fail(fmt.Errorf("no command given"))
+	}
+	argv := args[1:]
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+	}
+	if err := lockDown(writable); err != nil {
+		fail(err)
+	}
+	// the old working directory still points below the read-only mounts
+	if err := os.Chdir(wd); err != nil {
+		fail(err)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClear, 0); errno != 0 {
+		fail(fmt.Errorf("failed to drop capabilities: %w", errno))
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		fail(fmt.Errorf("failed to set no_new_privs: %w", errno))
+	}
+
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		fail(err)
+	}
+	fail(syscall.Exec(path, argv, os.Environ()))
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "argon sandbox: %v\n", err)
+	os.Exit(exitSetupFailed)
+}
+
+func lockDown(writable []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	// writable dirs become mounts of their own so the read-only pass skips them
+	for _, dir := range writable {
+		if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", dir, err)
+		}
+	}
+
+	mounts, err := readMounts()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if skipReadOnly(m.point, writable) {
+			continue
+		}
+		flags := uintptr(syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY) | m.locked
+		if err := syscall.Mount("", m.point, "", flags, ""); err != nil {
+			return fmt.Errorf("failed to make %s read-only: %w", m.point, err)
+		}
+	}
+	return nil
+}
+
+func skipReadOnly(point string, writable []string) bool {
+	for _, pseudo := range []string{"/proc", "/sys", "/dev"} {
+		if within(point, pseudo) {
+			return true
+		}
+	}
+	for _, dir := range writable {
+		if within(point, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+type mount struct {
+	point string
+	// flags an unprivileged remount has to keep
+	locked uintptr
+}
+
+var lockedFlags = map[string]uintptr{
+	"nosuid":      syscall.MS_NOSUID,
+	"nodev":       syscall.MS_NODEV,
+	"noexec":      syscall.MS_NOEXEC,
+	"noatime":     syscall.MS_NOATIME,
+	"nodiratime":  syscall.MS_NODIRATIME,
+	"relatime":    syscall.MS_RELATIME,
+	"strictatime": syscall.MS_STRICTATIME,
+}
+
+func readMounts() ([]mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mount-point options ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mount{point: unescape(fields[4])}
+		for _, opt := range strings.Split(fields[5], ",") {
+			m.locked |= lockedFlags[opt]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescape decodes the octal escapes mountinfo uses for spaces and friends.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const ArgonArchivesDir = ArgonCacheDir + "/archives"
+
+var archiveFormats = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", "tar.gz"},
+	{".tgz", "tar.gz"},
+	{".tar.xz", "tar.xz"},
+	{".txz", "tar.xz"},
+	{".tar.zst", "tar.zst"},
+	{".tzst", "tar.zst"},
+	{".tar", "tar"},
+	{".zip", "zip"},
+}
+
+func ArchiveFormat(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	for _, f := range archiveFormats {
+		if strings.HasSuffix(name, f.suffix) {
+			return f.format
+		}
+	}
+	return ""
+}
+
+func IsArchiveURL(spec string) bool {
+	return ArchiveFormat(spec) != "" && (strings.Contains(spec, "://") || IsLocalPath(spec))
+}
+
+func ArchiveName(spec string) string {
+	name := path.Base(strings.SplitN(strings.SplitN(spec, "?", 2)[0], "#", 2)[0])
+	lower := strings.ToLower(name)
+	for _, f := range archiveFormats {
+		if strings.HasSuffix(lower, f.suffix) {
+			name = name[:len(name)-len(f.suffix)]
+			break
+		}
+	}
+	// foo-1.2.3 and foo_v1.2 name the package foo
+	for i := 1; i < len(name)-1; i++ {
+		if (name[i] == '-' || name[i] == '_') && (isDigit(name[i+1]) || (name[i+1] == 'v' && i+2 < len(name) && isDigit(name[i+2]))) {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func ExtractArchive(archivePath, format, destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	destDir, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return err
+	}
+
+	if format == "zip" {
+		return extractZip(archivePath, destDir)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	case "tar.xz", "tar.zst":
+		tool := "xz"
+		if format == "tar.zst" {
+			tool = "zstd"
+		}
+		cmd := exec.Command(tool, "-dc")
+		cmd.Stdin = file
+		cmd.Stderr = os.Stderr
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("%s is required to extract %s archives: %w", tool, format, err)
+		}
+		extractErr := extractTar(out, destDir)
+		io.Copy(io.Discard, out)
+		if err := cmd.Wait(); err != nil && extractErr == nil {
+			return fmt.Errorf("%s failed: %w", tool, err)
+		}
+		return extractErr
+	case "tar":
+	default:
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+	return extractTar(reader, destDir)
+}
+
+func within(destDir, target string) bool {
+	return target == destDir || strings.HasPrefix(target, destDir+string(os.PathSeparator))
+}
+
+// entryPath resolves an archive entry name against destDir, following any
+// symlinks extracted earlier, and refuses anything that lands outside it.
+func entryPath(destDir, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+	target := filepath.Join(destDir, name)
+	if !within(destDir, target) {
+		return "", fmt.Errorf("archive entry %q escapes the extraction directory", name)
+	}
+	if target == destDir {
+		return target, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return "", err
+	}
+	if !within(destDir, parent) {
+		return "", fmt.Errorf("archive entry %q escapes the extraction directory", name)
+	}
+	return filepath.Join(parent, filepath.Base(target)), nil
+}
+
+func clearEntry(target string) error {
+	if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+		return os.Remove(target)
+	}
+	return nil
+}
+
+func checkLinkTarget(destDir, target, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return fmt.Errorf("symlink %s points to absolute path %s", target, linkname)
+	}
+	// ".." after a name could climb back out through another symlink
+	descended := false
+	for _, part := range strings.Split(linkname, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if descended {
+				return fmt.Errorf("symlink %s has a non-canonical target %s", target, linkname)
+			}
+		default:
+			descended = true
+		}
+	}
+	if !within(destDir, filepath.Join(filepath.Dir(target), linkname)) {
+		return fmt.Errorf("symlink %s points outside the extraction directory", target)
+	}
+	return nil
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm()&0777)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func extractTar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := entryPath(destDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := clearEntry(target); err != nil {
+				return err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := checkLinkTarget(destDir, target, hdr.Linkname); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			source, err := entryPath(destDir, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+func extractZip(archivePath, destDir string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		target, err := entryPath(destDir, f.Name)
+		if err != nil {
+			return err
+		}
+		mode := f.Mode()
+		if !mode.IsDir() {
+			if err := clearEntry(target); err != nil {
+				return err
+			}
+		}
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			linkname, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := checkLinkTarget(destDir, target, string(linkname)); err != nil {
+				return err
+			}
+			if err := os.Symlink(string(linkname), target); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			perm := mode.Perm()
+			if perm == 0 {
+				perm = 0644
+			}
+			err = writeFile(target, rc, perm)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type", f.Name)
+		}
+	}
+	return nil
+}
+
+func SourceRoot(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
@@ -0,0 +1,72 @@
+package utils
+
+import "sort"
+
+// BuildEnv adjusts how a package is compiled.
+type BuildEnv struct {
+	Env       map[string]string `json:"env,omitempty"`
+	MakeArgs  []string          `json:"make_args,omitempty"`
+	CMakeArgs []string          `json:"cmake_args,omitempty"`
+}
+
+// flagVars accumulate across layers instead of being replaced.
+var flagVars = map[string]bool{
+	"CFLAGS":    true,
+	"CXXFLAGS":  true,
+	"CPPFLAGS":  true,
+	"LDFLAGS":   true,
+	"RUSTFLAGS": true,
+}
+
+// MergeBuildEnv layers build settings in order: flag variables like CFLAGS
+// are joined with spaces, other variables are replaced by later layers and
+// extra make/cmake arguments are appended.
+func MergeBuildEnv(layers ...*BuildEnv) *BuildEnv {
+	merged := &BuildEnv{Env: map[string]string{}}
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		for key, value := range layer.Env {
+			if value == "" {
+				continue
+			}
+			if flagVars[key] && merged.Env[key] != "" {
+				merged.Env[key] += " " + value
+			} else {
+				merged.Env[key] = value
+			}
+		}
+		merged.MakeArgs = append(merged.MakeArgs, layer.MakeArgs...)
+		merged.CMakeArgs = append(merged.CMakeArgs, layer.CMakeArgs...)
+	}
+	return merged
+}
+
+func (e *BuildEnv) Empty() bool {
+	return len(e.Env) == 0 && len(e.MakeArgs) == 0 && len(e.CMakeArgs) == 0
+}
+
+// Environ returns the variables as KEY=value pairs in a stable order.
+func (e *BuildEnv) Environ() []string {
+	keys := make([]string, 0, len(e.Env))
+	for key := range e.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	environ := make([]string, 0, len(keys))
+	for _, key := range keys {
+		environ = append(environ, key+"="+e.Env[key])
+	}
+	return environ
+}
+
+// FlagsFromEnviron picks the flag variables out of an environment so they
+// can be merged instead of being overwritten.
+func FlagsFromEnviron(getenv func(string) string) *BuildEnv {
+	env := &BuildEnv{Env: map[string]string{}}
+	for key := range flagVars {
+		env.Env[key] = getenv(key)
+	}
+	return env
+}
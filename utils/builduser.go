@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+const BuildUserName = "argon"
+
+type BuildUser struct {
+	Name string
+	Uid  uint32
+	Gid  uint32
+	Home string
+}
+
+func LookupBuildUser(name string) (*BuildUser, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return buildUserFrom(u)
+}
+
+// DirOwner returns the account owning dir, used for in-place builds.
+func DirOwner(dir string) (*BuildUser, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("cannot determine owner of %s", dir)
+	}
+	u, err := user.LookupId(strconv.FormatUint(uint64(stat.Uid), 10))
+	if err != nil {
+		return nil, err
+	}
+	return buildUserFrom(u)
+}
+
+func buildUserFrom(u *user.User) (*BuildUser, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &BuildUser{Name: u.Username, Uid: uint32(uid), Gid: uint32(gid), Home: u.HomeDir}, nil
+}
+
+func (u *BuildUser) IsRoot() bool {
+	return u.Uid == 0
+}
+
+// Chown hands dir and everything below it to the build user.
+func (u *BuildUser) Chown(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, int(u.Uid), int(u.Gid))
+	})
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultHost        = "github.com"
+	ArgonSourcesDir    = ArgonCacheDir + "/sources"
+	ArgonBuildCacheDir = ArgonCacheDir + "/builds"
+)
+
+var vcsSchemes = []string{"git", "hg", "fossil"}
+
+var remoteAliases = map[string]string{
+	"gh":   "https://github.com/",
+	"gl":   "https://gitlab.com/",
+	"cb":   "https://codeberg.org/",
+	"srht": "https://git.sr.ht/",
+}
+
+var (
+	urlRewrites = map[string]string{}
+	hostMirrors = map[string][]string{}
+)
+
+func SetRemoteRewrites(rewrites map[string]string, mirrors map[string][]string) {
+	for prefix, replacement := range rewrites {
+		urlRewrites[prefix] = replacement
+	}
+	for host, urls := range mirrors {
+		hostMirrors[host] = urls
+	}
+}
+
+func rewriteURL(url string) string {
+	best := ""
+	for prefix := range urlRewrites {
+		if strings.HasPrefix(url, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return url
+	}
+	return urlRewrites[best] + strings.TrimPrefix(url, best)
+}
+
+func SetRemoteAliases(aliases map[string]string) {
+	for name, prefix := range aliases {
+		remoteAliases[strings.TrimSuffix(name, ":")] = prefix
+	}
+}
+
+func expandAlias(spec string) string {
+	name, rest, ok := strings.Cut(spec, ":")
+	if !ok || strings.Contains(name, "/") || strings.HasPrefix(rest, "//") {
+		return spec
+	}
+	prefix, ok := remoteAliases[name]
+	if !ok {
+		return spec
+	}
+	rest = strings.TrimLeft(rest, "/")
+	switch {
+	case strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, ":"):
+		return prefix + rest
+	case strings.Contains(prefix, "://"):
+		return prefix + "/" + rest
+	}
+	return prefix + ":" + rest
+}
+
+type Remote struct {
+	VCS    string
+	Scheme string
+	User   string
+	Host   string
+	Port   string
+	Path   string
+	SCP    bool
+}
+
+func ParseRemote(spec string) (Remote, error) {
+	spec = expandAlias(strings.TrimSpace(spec))
+	if spec == "" {
+		return Remote{}, fmt.Errorf("empty repository URL")
+	}
+
+	// hg+https://host/repo selects the backend, git is the default
+	for _, name := range vcsSchemes {
+		if rest, ok := strings.CutPrefix(spec, name+"+"); ok && strings.Contains(rest, "://") {
+			remote, err := ParseRemote(rest)
+			if name != "git" {
+				remote.VCS = name
+			}
+			return remote, err
+		}
+	}
+
+	if strings.Contains(spec, "://") {
+		u, err := url.Parse(spec)
+		if err != nil {
+			return Remote{}, fmt.Errorf("invalid repository URL %q: %w", spec, err)
+		}
+		remote := Remote{
+			Scheme: strings.ToLower(u.Scheme),
+			Host:   u.Hostname(),
+			Port:   u.Port(),
+			Path:   strings.Trim(u.Path, "/"),
+		}
+		if u.User != nil {
+			remote.User = u.User.Username()
+		}
+		switch remote.Scheme {
+		case "file":
+			if remote.Path == "" {
+				return Remote{}, fmt.Errorf("invalid repository URL %q: missing path", spec)
+			}
+		case "http", "https", "ssh", "git", "git+ssh", "ssh+git":
+			if remote.Host == "" || remote.Path == "" {
+				return Remote{}, fmt.Errorf("invalid repository URL %q: missing host or path", spec)
+			}
+			if strings.Contains(remote.Scheme, "ssh") {
+				remote.Scheme = "ssh"
+			}
+		default:
+			return Remote{}, fmt.Errorf("unsupported URL scheme %q", remote.Scheme)
+		}
+		return remote, nil
+	}
+
+	// scp-style user@host:path, recognised by a colon before the first slash
+	if colon := strings.Index(spec, ":"); colon > 0 {
+		slash := strings.Index(spec, "/")
+		if slash < 0 || colon < slash {
+			hostPart, repoPath := spec[:colon], strings.Trim(spec[colon+1:], "/")
+			remote := Remote{Scheme: "ssh", Host: hostPart, Path: repoPath, SCP: true}
+			if user, host, ok := strings.Cut(hostPart, "@"); ok {
+				remote.User, remote.Host = user, host
+			}
+			if remote.Host == "" || remote.Path == "" {
+				return Remote{}, fmt.Errorf("invalid repository URL %q", spec)
+			}
+			return remote, nil
+		}
+	}
+
+	parts := strings.Split(strings.Trim(spec, "/"), "/")
+	if len(parts) > 2 && (strings.Contains(parts[0], ".") || parts[0] == "localhost") {
+		return Remote{Scheme: "https", Host: parts[0], Path: strings.Join(parts[1:], "/")}, nil
+	}
+	if len(parts) < 2 {
+		return Remote{}, fmt.Errorf("invalid package %q (want owner/repo or a URL)", spec)
+	}
+	return Remote{Scheme: "https", Host: DefaultHost, Path: strings.Join(parts, "/")}, nil
+}
+
+func (r Remote) String() string {
+	if r.VCS != "" {
+		return r.VCS + "+" + r.URL()
+	}
+	return r.URL()
+}
+
+func (r Remote) URL() string {
+	if r.SCP {
+		if r.User != "" {
+			return fmt.Sprintf("%s@%s:%s", r.User, r.Host, r.Path)
+		}
+		return fmt.Sprintf("%s:%s", r.Host, r.Path)
+	}
+	if r.Scheme == "file" {
+		return "file:///" + r.Path
+	}
+	u := url.URL{Scheme: r.Scheme, Host: r.Host, Path: "/" + r.Path}
+	if r.Port != "" {
+		u.Host = r.Host + ":" + r.Port
+	}
+	if r.User != "" {
+		u.User = url.User(r.User)
+	}
+	return u.String()
+}
+
+// FetchURLs lists the URLs to try in order: the canonical URL after
+// rewrites, then each configured mirror for the host.
+func (r Remote) FetchURLs() []string {
+	urls := []string{rewriteURL(r.URL())}
+	host := r.Host
+	if r.Port != "" {
+		host += ":" + r.Port
+	}
+	for _, mirror := range hostMirrors[host] {
+		url := strings.TrimSuffix(mirror, "/") + "/" + r.Path
+		if url != urls[0] {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func (r Remote) Name() string {
+	return strings.TrimSuffix(path.Base(r.Path), ".git")
+}
+
+func (r Remote) Key() string {
+	host := r.Host
+	if r.Scheme == "file" {
+		host = "file"
+	}
+	if r.Port != "" {
+		host += "_" + r.Port
+	}
+	segments := []string{host}
+	for _, segment := range strings.Split(strings.TrimSuffix(r.Path, ".git"), "/") {
+		if segment != "" && segment != "." && segment != ".." {
+			segments = append(segments, segment)
+		}
+	}
+	return path.Join(segments...)
+}
+
+func SourceCachePath(remote Remote) string {
+	suffix := ".git"
+	if remote.VCS != "" {
+		suffix = "." + remote.VCS
+	}
+	return filepath.Join(ArgonSourcesDir, remote.Key()+suffix)
+}
+
+func NormalizeRemote(spec string) (string, error) {
+	remote, err := ParseRemote(spec)
+	if err != nil {
+		return "", err
+	}
+	return remote.String(), nil
+}
+
+// SplitSubdir separates a monorepo path from a spec like owner/repo//tools/foo.
+func SplitSubdir(spec string) (string, string) {
+	start := 0
+	if i := strings.Index(spec, "://"); i >= 0 {
+		start = i + 3
+	}
+	i := strings.Index(spec[start:], "//")
+	if i < 0 {
+		return spec, ""
+	}
+	return spec[:start+i], spec[start+i+2:]
+}
+
+func CleanSubdir(subdir string) (string, error) {
+	if subdir == "" {
+		return "", nil
+	}
+	clean := path.Clean("/" + subdir)[1:]
+	if clean == "" || strings.HasPrefix(subdir, "/") || strings.Contains("/"+subdir+"/", "/../") {
+		return "", fmt.Errorf("invalid subdirectory %q", subdir)
+	}
+	return clean, nil
+}
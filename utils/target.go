@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	LibcGNU  = "gnu"
+	LibcMusl = "musl"
+)
+
+// Target is the platform a package is built for.
+type Target struct {
+	Arch string
+	OS   string
+	Libc string
+}
+
+// archNames spells each architecture the way every ecosystem expects it.
+type archNames struct {
+	rust  string
+	zig   string
+	gnu   string
+	cmake string
+}
+
+var arches = map[string]archNames{
+	"x86_64":      {"x86_64", "x86_64", "x86_64", "x86_64"},
+	"aarch64":     {"aarch64", "aarch64", "aarch64", "aarch64"},
+	"i686":        {"i686", "x86", "i686", "i686"},
+	"armv7":       {"armv7", "arm", "arm", "armv7l"},
+	"riscv64":     {"riscv64gc", "riscv64", "riscv64", "riscv64"},
+	"powerpc64le": {"powerpc64le", "powerpc64le", "powerpc64le", "ppc64le"},
+	"s390x":       {"s390x", "s390x", "s390x", "s390x"},
+	"loongarch64": {"loongarch64", "loongarch64", "loongarch64", "loongarch64"},
+}
+
+var archAliases = map[string]string{
+	"amd64":   "x86_64",
+	"x64":     "x86_64",
+	"arm64":   "aarch64",
+	"386":     "i686",
+	"i386":    "i686",
+	"x86":     "i686",
+	"arm":     "armv7",
+	"armhf":   "armv7",
+	"armv7l":  "armv7",
+	"ppc64le": "powerpc64le",
+	"loong64": "loongarch64",
+}
+
+func canonicalArch(arch string) (string, bool) {
+	if alias, ok := archAliases[arch]; ok {
+		arch = alias
+	}
+	if strings.HasPrefix(arch, "riscv64") {
+		arch = "riscv64"
+	}
+	_, ok := arches[arch]
+	return arch, ok
+}
+
+func HostTarget() Target {
+	arch, _ := canonicalArch(runtime.GOARCH)
+	libc := LibcGNU
+	if matches, _ := filepath.Glob("/lib/ld-musl-*.so.1"); len(matches) > 0 {
+		libc = LibcMusl
+	}
+	return Target{Arch: arch, OS: "linux", Libc: libc}
+}
+
+// ResolveTarget parses a --target value like aarch64, arm64-linux-musl or
+// x86_64-unknown-linux-gnu. Without an explicit libc, musl builds use musl
+// and everything else the host's libc.
+func ResolveTarget(spec string, musl bool) (Target, error) {
+	host := HostTarget()
+	target := Target{Arch: host.Arch, OS: host.OS}
+
+	if spec != "" {
+		parts := strings.Split(strings.ToLower(spec), "-")
+		arch, ok := canonicalArch(parts[0])
+		if !ok {
+			return target, fmt.Errorf("unsupported target architecture %q", parts[0])
+		}
+		target.Arch = arch
+		for _, part := range parts[1:] {
+			switch {
+			case part == "unknown" || part == "pc" || part == "none":
+			case part == "linux":
+				target.OS = "linux"
+			case strings.HasPrefix(part, LibcMusl):
+				target.Libc = LibcMusl
+			case strings.HasPrefix(part, LibcGNU):
+				target.Libc = LibcGNU
+			default:
+				return target, fmt.Errorf("unsupported target %q (only linux with gnu or musl is supported)", spec)
+			}
+		}
+	}
+
+	if target.Libc == "" {
+		target.Libc = host.Libc
+		if musl {
+			target.Libc = LibcMusl
+		}
+	}
+	return target, nil
+}
+
+func (t Target) String() string {
+	return t.Arch + "-" + t.OS + "-" + t.Libc
+}
+
+// Native reports whether the host toolchain builds for t without a target flag.
+func (t Target) Native() bool {
+	host := HostTarget()
+	return t.Arch == host.Arch && t.Libc == host.Libc
+}
+
+// Cross reports whether C compilers for another architecture are needed.
+func (t Target) Cross() bool {
+	return t.Arch != HostTarget().Arch
+}
+
+func (t Target) abi() string {
+	if t.Arch == "armv7" {
+		return t.Libc + "eabihf"
+	}
+	return t.Libc
+}
+
+func (t Target) Rust() string {
+	return arches[t.Arch].rust + "-unknown-" + t.OS + "-" + t.abi()
+}
+
+func (t Target) Zig() string {
+	return arches[t.Arch].zig + "-" + t.OS + "-" + t.abi()
+}
+
+// GNUTriple is the configure --host value and cross-compiler prefix.
+func (t Target) GNUTriple() string {
+	return arches[t.Arch].gnu + "-" + t.OS + "-" + t.abi()
+}
+
+func (t Target) CMakeToolchain() string {
+	prefix := t.GNUTriple() + "-"
+	return fmt.Sprintf(`set(CMAKE_SYSTEM_NAME Linux)
+set(CMAKE_SYSTEM_PROCESSOR %s)
+set(CMAKE_C_COMPILER %sgcc)
+set(CMAKE_CXX_COMPILER %sg++)
+set(CMAKE_FIND_ROOT_PATH_MODE_PROGRAM NEVER)
+`, arches[t.Arch].cmake, prefix, prefix)
+}
+
+// CrossEnv names the cross toolchain for make-style builds.
+func (t Target) CrossEnv() map[string]string {
+	if !t.Cross() {
+		return nil
+	}
+	prefix := t.GNUTriple() + "-"
+	linker := "CARGO_TARGET_" + strings.ToUpper(strings.ReplaceAll(t.Rust(), "-", "_")) + "_LINKER"
+	return map[string]string{
+		"CC":    prefix + "gcc",
+		"CXX":   prefix + "g++",
+		"AR":    prefix + "ar",
+		"STRIP": prefix + "strip",
+		linker:  prefix + "gcc",
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Requirement is a command a build needs, optionally in a minimum version.
+type Requirement struct {
+	Command    string
+	Constraint string
+}
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// ParseRequirement reads "cmake" or "cmake>=3.20".
+func ParseRequirement(s string) (Requirement, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, "<>=^~ ")
+	if i < 0 {
+		return Requirement{Command: s}, nil
+	}
+	req := Requirement{Command: s[:i], Constraint: strings.TrimSpace(s[i:])}
+	if req.Command == "" {
+		return req, fmt.Errorf("invalid requirement %q", s)
+	}
+	if _, err := parseConstraints(req.Constraint); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+func (r Requirement) String() string {
+	if r.Constraint == "" {
+		return r.Command
+	}
+	return r.Command + " " + r.Constraint
+}
+
+// Check looks the command up in PATH and, when a constraint is set, compares
+// it against the first version number the command reports.
+func (r Requirement) Check() error {
+	path, err := exec.LookPath(r.Command)
+	if err != nil {
+		return fmt.Errorf("not found")
+	}
+	if r.Constraint == "" {
+		return nil
+	}
+	cs, err := parseConstraints(r.Constraint)
+	if err != nil {
+		return err
+	}
+	found, err := ToolVersion(path)
+	if err != nil {
+		return err
+	}
+	v, _ := parseVersion(found)
+	if !cs.allow(v) {
+		return fmt.Errorf("version %s does not satisfy %s", found, r.Constraint)
+	}
+	return nil
+}
+
+// ToolVersion asks a command for its version.
+func ToolVersion(command string) (string, error) {
+	args := []string{"--version"}
+	// zig has no --version flag
+	if strings.HasSuffix(command, "zig") {
+		args = []string{"version"}
+	}
+	out, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("could not get version: %w", err)
+	}
+	found := versionPattern.FindString(string(out))
+	if found == "" {
+		return "", fmt.Errorf("could not get version from %q", strings.TrimSpace(string(out)))
+	}
+	return found, nil
+}
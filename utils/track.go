@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	TrackHead      = "head"
+	TrackBranch    = "branch"
+	TrackLatestTag = "latest-tag"
+	TrackRef       = "ref"
+)
+
+type Track struct {
+	Kind   string
+	Branch string
+	Filter string
+	Ref    string
+}
+
+func ParseTrack(s string) (Track, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == TrackHead {
+		return Track{Kind: TrackHead}, nil
+	}
+	kind, value, _ := strings.Cut(s, ":")
+	switch kind {
+	case TrackBranch:
+		if value == "" {
+			return Track{}, fmt.Errorf("track %q is missing a branch name", s)
+		}
+		return Track{Kind: TrackBranch, Branch: value}, nil
+	case TrackLatestTag:
+		if value != "" && !isSemverFilter(value) {
+			if _, err := path.Match(value, ""); err != nil {
+				return Track{}, fmt.Errorf("invalid tag glob %q: %w", value, err)
+			}
+		}
+		if isSemverFilter(value) {
+			if _, err := parseConstraints(value); err != nil {
+				return Track{}, err
+			}
+		}
+		return Track{Kind: TrackLatestTag, Filter: value}, nil
+	case TrackRef:
+		if !strings.HasPrefix(value, "refs/") {
+			return Track{}, fmt.Errorf("track %q needs a full ref like refs/pull/1/head", s)
+		}
+		return Track{Kind: TrackRef, Ref: value}, nil
+	}
+	return Track{}, fmt.Errorf("unknown track %q (want head, branch:<name>, latest-tag[:<filter>] or ref:<refs/...>)", s)
+}
+
+func (t Track) String() string {
+	switch t.Kind {
+	case TrackBranch:
+		return TrackBranch + ":" + t.Branch
+	case TrackLatestTag:
+		if t.Filter != "" {
+			return TrackLatestTag + ":" + t.Filter
+		}
+		return TrackLatestTag
+	case TrackRef:
+		return TrackRef + ":" + t.Ref
+	}
+	return TrackHead
+}
+
+func isSemverFilter(filter string) bool {
+	return filter != "" && strings.ContainsAny(filter[:1], "<>=^~")
+}
+
+func (t Track) matchesTag(tag string) bool {
+	if t.Filter == "" {
+		_, ok := parseVersion(tag)
+		return ok
+	}
+	if isSemverFilter(t.Filter) {
+		v, ok := parseVersion(tag)
+		if !ok {
+			return false
+		}
+		constraints, err := parseConstraints(t.Filter)
+		if err != nil {
+			return false
+		}
+		return constraints.allow(v)
+	}
+	ok, _ := path.Match(t.Filter, tag)
+	return ok
+}
+
+func LatestTag(track Track, tags []string) (string, bool) {
+	best, bestIsVersion := "", false
+	var bestVersion version
+	for _, tag := range tags {
+		if !track.matchesTag(tag) {
+			continue
+		}
+		v, ok := parseVersion(tag)
+		if !ok {
+			if !bestIsVersion && (best == "" || compareNatural(tag, best) > 0) {
+				best = tag
+			}
+			continue
+		}
+		if v.pre != "" && !strings.Contains(track.Filter, "-") {
+			continue
+		}
+		if !bestIsVersion || v.compare(bestVersion) > 0 {
+			best, bestVersion, bestIsVersion = tag, v, true
+		}
+	}
+	return best, best != ""
+}
+
+// PullRequestRefs lists the refs a forge may publish a pull or merge request
+// under, the likeliest for the host first.
+func PullRequestRefs(remote Remote, number int) ([]string, error) {
+	if number <= 0 {
+		return nil, fmt.Errorf("invalid pull request number %d", number)
+	}
+	pull := fmt.Sprintf("refs/pull/%d/head", number)
+	merge := fmt.Sprintf("refs/merge-requests/%d/head", number)
+	if strings.Contains(remote.Host, "gitlab") {
+		return []string{merge, pull}, nil
+	}
+	return []string{pull, merge}, nil
+}
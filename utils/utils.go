@@ -2,7 +2,6 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,16 +9,46 @@ import (
 )
 
 type Package struct {
-	Name        string `json:"name"`
-	Repo        string `json:"repo"`
-	BuildSystem string `json:"build_system"`
-	Hash        string `json:"hash"`
-	Static      bool   `json:"static"`
+	Name        string            `json:"name"`
+	Repo        string            `json:"repo"`
+	BuildSystem string            `json:"build_system"`
+	Hash        string            `json:"hash"`
+	Static      bool              `json:"static"`
+	Track       string            `json:"track,omitempty"`
+	Ref         string            `json:"ref,omitempty"`
+	Smoke       *SmokeTest        `json:"smoke,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Dirty       bool              `json:"dirty,omitempty"`
+	InPlace     bool              `json:"in_place,omitempty"`
+	Digest      string            `json:"digest,omitempty"`
+	Submodules  map[string]string `json:"submodules,omitempty"`
+	Signer      string            `json:"signer,omitempty"`
+	Subdir      string            `json:"subdir,omitempty"`
+	PR          int               `json:"pr,omitempty"`
+	Sandbox     bool              `json:"sandbox,omitempty"`
+	Jobs        int               `json:"jobs,omitempty"`
+	Build       *BuildEnv         `json:"build,omitempty"`
+	Target      string            `json:"target,omitempty"`
+	CacheKey    string            `json:"cache_key,omitempty"`
 }
 
+type SmokeTest struct {
+	Args     []string `json:"args"`
+	Expect   string   `json:"expect,omitempty"`
+	ExitCode int      `json:"exit_code,omitempty"`
+}
+
+const (
+	SourceGit     = "git"
+	SourceLocal   = "local"
+	SourceArchive = "archive"
+)
+
 const (
-	ArgonLibDir  = "/var/lib/argon"
-	ArgonTempDir = "/tmp/argon"
+	ArgonLibDir   = "/var/lib/argon"
+	ArgonTempDir  = "/tmp/argon"
+	ArgonCacheDir = "/var/cache/argon"
+	ArgonLogsDir  = ArgonLibDir + "/logs"
 )
 
 func GetInstalledPackages() []Package {
@@ -52,7 +81,9 @@ func SaveInstalledPackages(packages []Package) error {
 
 func SetupArgonDirs() {
 	os.MkdirAll(filepath.Join(ArgonTempDir, "builds"), 0755)
+	os.MkdirAll(filepath.Join(ArgonTempDir, "tmp"), 0755)
 	os.MkdirAll(ArgonLibDir, 0755)
+	os.MkdirAll(ArgonSourcesDir, 0755)
 }
 
 func GetPrivilegeCommand() string {
@@ -66,43 +97,14 @@ func GetPrivilegeCommand() string {
 }
 
 func GetRepoName(pkg string) string {
-	parts := strings.Split(pkg, "/")
+	if remote, err := ParseRemote(pkg); err == nil {
+		return remote.Name()
+	}
+	parts := strings.Split(strings.TrimRight(pkg, "/"), "/")
 	name := parts[len(parts)-1]
 	return strings.TrimSuffix(name, ".git")
 }
 
-func GetDomainFromURL(pkg string) string {
-	if strings.Contains(pkg, "gitlab.com") {
-		return "gitlab.com"
-	}
-	if strings.Contains(pkg, "codeberg.org") {
-		return "codeberg.org"
-	}
-	if strings.Contains(pkg, "github.com") {
-		return "github.com"
-	}
-	parts := strings.Split(pkg, "/")
-	if len(parts) > 2 && strings.Contains(parts[0], ".") {
-		return parts[0]
-	}
-	return "github.com"
-}
-
-func ExtractRepoPath(pkg string) string {
-	parts := strings.Split(pkg, "://")
-	if len(parts) > 1 {
-		pkg = parts[1]
-	}
-	parts = strings.Split(pkg, "/")
-	start := 0
-	for i, part := range parts {
-		if strings.Contains(part, ".") {
-			start = i + 1
-		}
-	}
-	return strings.Join(parts[start:], "/")
-}
-
 func DirectoryExists(path string) bool {
 	info, err := os.Stat(path)
 	return err == nil && info.IsDir()
@@ -131,39 +133,7 @@ func CreateDirectory(path string) error {
 	return os.MkdirAll(path, 0755)
 }
 
-func GetGitHash(buildDir string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	cmd.Dir = buildDir
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(output)), nil
-}
-
-func GetRemoteHash(repoURL string, branch string) (string, error) {
-	domain := GetDomainFromURL(repoURL)
-	repoPath := ExtractRepoPath(repoURL)
-	
-	args := []string{"ls-remote", fmt.Sprintf("https://%s/%s", domain, repoPath)}
-	if branch != "" {
-		args = append(args, fmt.Sprintf("refs/heads/%s", branch))
-	} else {
-		args = append(args, "HEAD")
-	}
-	
-	cmd := exec.Command("git", args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) > 0 && lines[0] != "" {
-		parts := strings.Fields(lines[0])
-		if len(parts) > 0 {
-			return parts[0], nil
-		}
-	}
-	return "", fmt.Errorf("no hash found in remote response")
+func IsLocalPath(spec string) bool {
+	return spec == "." || spec == ".." || strings.HasPrefix(spec, "/") ||
+		strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../")
 }
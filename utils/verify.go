@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	VerifyCommit = "commit"
+	VerifyTag    = "tag"
+)
+
+type TrustPolicy struct {
+	Verify         string `json:"verify"`
+	GPGKeyring     string `json:"gpg_keyring,omitempty"`
+	AllowedSigners string `json:"allowed_signers,omitempty"`
+}
+
+func VerifySignature(worktree, ref string, policy TrustPolicy) (string, error) {
+	if policy.GPGKeyring == "" && policy.AllowedSigners == "" {
+		return "", fmt.Errorf("trust policy needs gpg_keyring or allowed_signers")
+	}
+
+	args := []string{}
+	if policy.AllowedSigners != "" {
+		if !FileExists(policy.AllowedSigners) {
+			return "", fmt.Errorf("allowed signers file not found: %s", policy.AllowedSigners)
+		}
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+policy.AllowedSigners)
+	}
+
+	switch policy.Verify {
+	case VerifyCommit:
+		args = append(args, "verify-commit", "--raw", "HEAD")
+	case VerifyTag:
+		if ref == "" || !isTag(worktree, ref) {
+			return "", fmt.Errorf("tag verification requires a tag, but %s is not checked out from one", describeRef(ref))
+		}
+		args = append(args, "verify-tag", "--raw", ref)
+	default:
+		return "", fmt.Errorf("unknown trust policy %q (want commit or tag)", policy.Verify)
+	}
+
+	// an isolated GNUPGHOME keeps keys from root's own keyring out of it
+	home, err := gpgHomeWithKeyring(policy.GPGKeyring)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(home)
+	env := append(os.Environ(), "GNUPGHOME="+home)
+
+	var output bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = worktree
+	cmd.Env = env
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(output.String())
+		if detail == "" {
+			detail = describeRef(ref) + " is not signed"
+		}
+		return "", fmt.Errorf("signature verification failed: %s", detail)
+	}
+
+	signer := signerFingerprint(output.String())
+	if signer == "" {
+		return "", fmt.Errorf("signature verification returned no signer")
+	}
+	return signer, nil
+}
+
+func describeRef(ref string) string {
+	if ref == "" {
+		return "HEAD"
+	}
+	return ref
+}
+
+func isTag(worktree, ref string) bool {
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/tags/"+ref)
+	cmd.Dir = worktree
+	return cmd.Run() == nil
+}
+
+func gpgHomeWithKeyring(keyring string) (string, error) {
+	if keyring != "" && !FileExists(keyring) {
+		return "", fmt.Errorf("gpg keyring not found: %s", keyring)
+	}
+	home, err := os.MkdirTemp("", "argon-gnupg-")
+	if err != nil {
+		return "", err
+	}
+	if keyring == "" {
+		return home, nil
+	}
+	cmd := exec.Command("gpg", "--batch", "--quiet", "--homedir", home, "--import", keyring)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(home)
+		return "", fmt.Errorf("failed to import %s: %s", keyring, strings.TrimSpace(string(output)))
+	}
+	return home, nil
+}
+
+func signerFingerprint(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		// [GNUPG:] VALIDSIG <fpr> ... [<primary-key-fpr>]
+		if len(fields) >= 3 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG" {
+			if len(fields) >= 12 {
+				return fields[11]
+			}
+			return fields[2]
+		}
+		// Good "git" signature for <principal> with <ALGO> key SHA256:...
+		if strings.HasPrefix(line, "Good \"git\" signature") {
+			for _, field := range fields {
+				if strings.HasPrefix(field, "SHA256:") {
+					return field
+				}
+			}
+		}
+	}
+	return ""
+}
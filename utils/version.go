@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type version struct {
+	parts [3]int
+	pre   string
+}
+
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.pre = s[:i], s[i+1:]
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return v, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+func (v version) compare(other version) int {
+	for i := range v.parts {
+		if v.parts[i] != other.parts[i] {
+			if v.parts[i] < other.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.pre == other.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case other.pre == "":
+		return -1
+	}
+	return compareNatural(v.pre, other.pre)
+}
+
+type constraint struct {
+	op string
+	v  version
+}
+
+type constraints []constraint
+
+func parseConstraints(s string) (constraints, error) {
+	var result constraints
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		op := field[:len(field)-len(strings.TrimLeft(field, "<>=^~"))]
+		v, ok := parseVersion(field[len(op):])
+		if !ok {
+			return nil, fmt.Errorf("invalid version constraint %q", field)
+		}
+		switch op {
+		case "^":
+			upper := version{}
+			switch {
+			case v.parts[0] > 0:
+				upper.parts[0] = v.parts[0] + 1
+			case v.parts[1] > 0:
+				upper.parts[1] = v.parts[1] + 1
+			default:
+				upper.parts[2] = v.parts[2] + 1
+			}
+			result = append(result, constraint{">=", v}, constraint{"<", upper})
+		case "~":
+			upper := version{}
+			upper.parts[0] = v.parts[0]
+			upper.parts[1] = v.parts[1] + 1
+			if strings.Count(field, ".") == 0 {
+				upper.parts[0], upper.parts[1] = v.parts[0]+1, 0
+			}
+			result = append(result, constraint{">=", v}, constraint{"<", upper})
+		case ">=", "<=", ">", "<", "=", "":
+			if op == "" {
+				op = "="
+			}
+			result = append(result, constraint{op, v})
+		default:
+			return nil, fmt.Errorf("invalid version constraint %q", field)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("empty version constraint")
+	}
+	return result, nil
+}
+
+func (cs constraints) allow(v version) bool {
+	for _, c := range cs {
+		cmp := v.compare(c.v)
+		ok := false
+		switch c.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "=":
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		ca, cb := chunk(a), chunk(b)
+		a, b = a[len(ca):], b[len(cb):]
+		na, errA := strconv.Atoi(ca)
+		nb, errB := strconv.Atoi(cb)
+		if errA == nil && errB == nil {
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(ca, cb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func chunk(s string) string {
+	digit := unicode.IsDigit(rune(s[0]))
+	for i, r := range s {
+		if unicode.IsDigit(r) != digit {
+			return s[:i]
+		}
+	}
+	return s
+}
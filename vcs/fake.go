@@ -0,0 +1,107 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Fake is an in-memory backend for tests. Register it with Register and
+// point a remote at it by setting its VCS to "fake".
+type Fake struct {
+	mu sync.Mutex
+
+	// Refs maps a ref ("" for the default branch) to its revision.
+	Refs map[string]string
+	Tags map[string]string
+	// Files are written into every checkout.
+	Files map[string]string
+
+	Fetches []string
+	Clones  []string
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Fetch(urls []string, cacheDir string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(urls) == 0 {
+		return fmt.Errorf("no urls to fetch")
+	}
+	f.Fetches = append(f.Fetches, urls[0])
+	return os.MkdirAll(cacheDir, 0755)
+}
+
+func (f *Fake) FetchRef(urls []string, cacheDir, ref string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.Refs[ref]; !ok {
+		return fmt.Errorf("ref %q not found", ref)
+	}
+	f.Fetches = append(f.Fetches, ref)
+	return nil
+}
+
+func (f *Fake) Clone(cacheDir, dest, rev string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	revision, ok := f.resolve(rev)
+	if !ok {
+		return fmt.Errorf("unknown revision %q", rev)
+	}
+	f.Clones = append(f.Clones, dest)
+	for name, content := range f.Files {
+		path := filepath.Join(dest, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dest, ".fake-revision"), []byte(revision), 0644)
+}
+
+func (f *Fake) resolve(rev string) (string, bool) {
+	if hash, ok := f.Refs[rev]; ok {
+		return hash, true
+	}
+	if hash, ok := f.Tags[rev]; ok {
+		return hash, true
+	}
+	for _, hash := range f.Refs {
+		if hash == rev {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+func (f *Fake) Revision(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ".fake-revision"))
+	return string(data), err
+}
+
+func (f *Fake) RemoteRevision(urls []string, cacheDir, ref string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hash, ok := f.Refs[ref]
+	if !ok {
+		return "", fmt.Errorf("ref %q not found", ref)
+	}
+	return hash, nil
+}
+
+func (f *Fake) RemoteTags(urls []string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tags := make(map[string]string, len(f.Tags))
+	for name, hash := range f.Tags {
+		tags[name] = hash
+	}
+	return tags, nil
+}
@@ -0,0 +1,106 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"argon-go/utils"
+)
+
+func newFake(t *testing.T) (*Fake, utils.Remote) {
+	t.Helper()
+	fake := &Fake{
+		Refs:  map[string]string{"": "aaa111", "main": "aaa111", "dev": "bbb222"},
+		Tags:  map[string]string{"v1.0.0": "ccc333", "v1.2.0": "ddd444", "v1.10.0": "eee555", "nightly": "fff666"},
+		Files: map[string]string{"Makefile": "all:\n", "src/main.c": "int main(void) { return 0; }\n"},
+	}
+	Register(fake)
+	t.Cleanup(func() { delete(backends, fake.Name()) })
+
+	remote, err := utils.ParseRemote("https://example.com/argon/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.VCS = fake.Name()
+	return fake, remote
+}
+
+func TestFakeRegistered(t *testing.T) {
+	fake, remote := newFake(t)
+	if backend, err := Get("fake"); err != nil || backend != fake {
+		t.Fatalf("Get(fake) = %v, %v", backend, err)
+	}
+	if backend, err := ForRemote(remote); err != nil || backend != fake {
+		t.Fatalf("ForRemote = %v, %v", backend, err)
+	}
+}
+
+func TestFakeClone(t *testing.T) {
+	fake, remote := newFake(t)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	if err := fake.Fetch(remote.FetchURLs(), cacheDir); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "hello")
+	if err := CloneSubdir(fake, cacheDir, dest, "dev", ""); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range fake.Files {
+		got, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if rev, err := fake.Revision(dest); err != nil || rev != "bbb222" {
+		t.Errorf("Revision = %q, %v, want bbb222", rev, err)
+	}
+	if len(fake.Clones) != 1 || fake.Clones[0] != dest {
+		t.Errorf("Clones = %v", fake.Clones)
+	}
+
+	if err := fake.Clone(cacheDir, filepath.Join(t.TempDir(), "missing"), "nope"); err == nil {
+		t.Error("clone of an unknown revision succeeded")
+	}
+}
+
+func TestFakeRemoteHash(t *testing.T) {
+	fake, remote := newFake(t)
+	tests := []struct {
+		track string
+		hash  string
+		ref   string
+	}{
+		{"head", "aaa111", ""},
+		{"branch:dev", "bbb222", "dev"},
+		{"latest-tag", "eee555", "v1.10.0"},
+		{"latest-tag:<1.5", "ddd444", "v1.2.0"},
+		{"latest-tag:nightly*", "fff666", "nightly"},
+	}
+	for _, tt := range tests {
+		track, err := utils.ParseTrack(tt.track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, ref, err := RemoteHash(fake, remote, track)
+		if err != nil {
+			t.Errorf("%s: %v", tt.track, err)
+			continue
+		}
+		if hash != tt.hash || ref != tt.ref {
+			t.Errorf("%s: got %s %q, want %s %q", tt.track, hash, ref, tt.hash, tt.ref)
+		}
+	}
+
+	if _, _, err := RemoteHash(fake, remote, utils.Track{Kind: utils.TrackBranch, Branch: "gone"}); err == nil {
+		t.Error("missing branch resolved")
+	}
+	track, _ := utils.ParseTrack("latest-tag:>=2")
+	if _, _, err := RemoteHash(fake, remote, track); err == nil {
+		t.Error("latest-tag with no match resolved")
+	}
+}
@@ -0,0 +1,81 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"argon-go/utils"
+)
+
+type Fossil struct{}
+
+func (Fossil) Name() string {
+	return "fossil"
+}
+
+func (Fossil) Fetch(urls []string, cacheDir string) error {
+	return tryURLs(urls, func(url string) error {
+		if utils.FileExists(cacheDir) {
+			fmt.Printf("Fetching updates into %s\n", cacheDir)
+			return run("", "fossil", "pull", url, "-R", cacheDir)
+		}
+		if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+			return err
+		}
+		tmpFile := cacheDir + ".tmp"
+		os.Remove(tmpFile)
+		if err := run("", "fossil", "clone", url, tmpFile); err != nil {
+			os.Remove(tmpFile)
+			return err
+		}
+		return os.Rename(tmpFile, cacheDir)
+	})
+}
+
+func (Fossil) Clone(cacheDir, dest, rev string) error {
+	if rev == "" {
+		rev = "trunk"
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	return run(dest, "fossil", "open", cacheDir, rev)
+}
+
+func (Fossil) Revision(dir string) (string, error) {
+	out, err := output(dir, "fossil", "info")
+	if err != nil {
+		return "", err
+	}
+	return infoHash(out, "checkout:")
+}
+
+// Fossil has no ls-remote, so the remote revision is read from a freshly
+// pulled cache.
+func (f Fossil) RemoteRevision(urls []string, cacheDir, ref string) (string, error) {
+	if ref == "" {
+		ref = "trunk"
+	}
+	if err := f.Fetch(urls, cacheDir); err != nil {
+		return "", err
+	}
+	out, err := output("", "fossil", "info", ref, "-R", cacheDir)
+	if err != nil {
+		return "", err
+	}
+	return infoHash(out, "hash:")
+}
+
+func infoHash(info, key string) (string, error) {
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(line, key); ok {
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				return fields[0], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s line in fossil info output", strings.TrimSuffix(key, ":"))
+}
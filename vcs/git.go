@@ -0,0 +1,265 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"argon-go/utils"
+)
+
+type Git struct{}
+
+func (Git) Name() string {
+	return "git"
+}
+
+func (Git) Fetch(urls []string, cacheDir string) error {
+	return tryURLs(urls, func(url string) error {
+		return syncMirrorFrom(url, cacheDir)
+	})
+}
+
+func syncMirrorFrom(url, mirrorDir string) error {
+	if utils.DirectoryExists(mirrorDir) {
+		fmt.Printf("Fetching updates into %s\n", mirrorDir)
+		if err := run(mirrorDir, "git", "remote", "set-url", "origin", url); err != nil {
+			return err
+		}
+		return run(mirrorDir, "git", "fetch", "--prune", "--tags", "origin")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(mirrorDir), 0755); err != nil {
+		return err
+	}
+	tmpDir := mirrorDir + ".tmp"
+	os.RemoveAll(tmpDir)
+	if err := run("", "git", "clone", "--bare", url, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := run(tmpDir, "git", "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	return os.Rename(tmpDir, mirrorDir)
+}
+
+func (Git) FetchRef(urls []string, cacheDir, ref string) error {
+	return tryURLs(urls, func(url string) error {
+		return run(cacheDir, "git", "fetch", url, "+"+ref+":"+ref)
+	})
+}
+
+func (g Git) Clone(cacheDir, dest, rev string) error {
+	g.Prune(cacheDir)
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if err := run(cacheDir, "git", "worktree", "add", "--detach", "--force", dest, rev); err != nil {
+		return err
+	}
+	if err := UpdateSubmodules(dest); err != nil {
+		return fmt.Errorf("failed to fetch submodules: %w", err)
+	}
+	return nil
+}
+
+func (g Git) CloneSparse(cacheDir, dest, rev string, paths []string) error {
+	g.Prune(cacheDir)
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if err := run(cacheDir, "git", "worktree", "add", "--detach", "--force", "--no-checkout", dest, rev); err != nil {
+		return err
+	}
+	if err := run(dest, "git", append([]string{"sparse-checkout", "set", "--cone"}, paths...)...); err != nil {
+		return err
+	}
+	if err := run(dest, "git", "checkout"); err != nil {
+		return err
+	}
+	if err := UpdateSubmodules(dest); err != nil {
+		return fmt.Errorf("failed to fetch submodules: %w", err)
+	}
+	return nil
+}
+
+func (Git) Prune(cacheDir string) {
+	if !utils.DirectoryExists(cacheDir) {
+		return
+	}
+	cmd := exec.Command("git", "worktree", "prune")
+	cmd.Dir = cacheDir
+	cmd.Run()
+}
+
+func (Git) Revision(dir string) (string, error) {
+	return output(dir, "git", "rev-parse", "HEAD")
+}
+
+func (Git) RemoteRevision(urls []string, cacheDir, ref string) (string, error) {
+	pattern := "HEAD"
+	if strings.HasPrefix(ref, "refs/") {
+		pattern = ref
+	} else if ref != "" {
+		pattern = "refs/heads/" + ref
+	}
+	refs, err := lsRemote(urls, pattern)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := refs[pattern]; ok {
+		return hash, nil
+	}
+	if pattern == ref {
+		return "", fmt.Errorf("ref %s not found on remote", ref)
+	}
+	if ref != "" {
+		return "", fmt.Errorf("branch %s not found on remote", ref)
+	}
+	return "", fmt.Errorf("no hash found in remote response")
+}
+
+func (Git) RemoteTags(urls []string) (map[string]string, error) {
+	refs, err := lsRemote(urls, "refs/tags/*")
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]string{}
+	for ref, hash := range refs {
+		name, ok := strings.CutPrefix(ref, "refs/tags/")
+		if !ok {
+			continue
+		}
+		// annotated tags are listed twice, the peeled entry points at the commit
+		if peeled, ok := strings.CutSuffix(name, "^{}"); ok {
+			hashes[peeled] = hash
+		} else if _, seen := hashes[name]; !seen {
+			hashes[name] = hash
+		}
+	}
+	return hashes, nil
+}
+
+func lsRemote(urls []string, patterns ...string) (map[string]string, error) {
+	var out []byte
+	var err error
+	for _, url := range urls {
+		cmd := exec.Command("git", append([]string{"ls-remote", url}, patterns...)...)
+		if out, err = cmd.Output(); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 2 {
+			refs[parts[1]] = parts[0]
+		}
+	}
+	return refs, nil
+}
+
+func UpdateSubmodules(worktree string) error {
+	if !utils.FileExists(filepath.Join(worktree, ".gitmodules")) {
+		return nil
+	}
+	fmt.Println("Fetching submodules...")
+	return updateSubmodules(worktree)
+}
+
+// updateSubmodules fetches each submodule through the same URL rewrites and
+// mirrors as its superproject, then descends into nested submodules.
+func updateSubmodules(worktree string) error {
+	out, err := output(worktree, "git", "config", "-f", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	if err != nil || out == "" {
+		// git config exits 1 when nothing matches
+		return nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		key, path, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "submodule."), ".path")
+		url, err := output(worktree, "git", "config", "-f", ".gitmodules", "submodule."+name+".url")
+		if err != nil {
+			return fmt.Errorf("submodule %s has no url", path)
+		}
+		err = tryURLs(submoduleURLs(url), func(url string) error {
+			// passed per command: the worktree shares its config with the source cache
+			update := []string{"-c", "submodule." + name + ".url=" + url, "submodule", "update", "--init"}
+			if err := run(worktree, "git", append(update, "--depth=1", "--", path)...); err != nil {
+				fmt.Println("Shallow submodule fetch failed, retrying with full history...")
+				return run(worktree, "git", append(update, "--", path)...)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("submodule %s: %w", path, err)
+		}
+		if nested := filepath.Join(worktree, path); utils.FileExists(filepath.Join(nested, ".gitmodules")) {
+			if err := updateSubmodules(nested); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// submoduleURLs leaves relative URLs to git, which resolves them against
+// the superproject's remote.
+func submoduleURLs(url string) []string {
+	if strings.HasPrefix(url, "./") || strings.HasPrefix(url, "../") {
+		return []string{url}
+	}
+	remote, err := utils.ParseRemote(url)
+	if err != nil {
+		return []string{url}
+	}
+	return remote.FetchURLs()
+}
+
+func SubmoduleHashes(worktree string) (map[string]string, error) {
+	if !utils.FileExists(filepath.Join(worktree, ".gitmodules")) {
+		return nil, nil
+	}
+	out, err := output(worktree, "git", "submodule", "status", "--recursive")
+	if err != nil {
+		return nil, err
+	}
+	hashes := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(strings.TrimLeft(line, " -+U"))
+		if len(fields) >= 2 {
+			hashes[fields[1]] = fields[0]
+		}
+	}
+	return hashes, nil
+}
+
+// SubmodulePins returns the commits rev pins its submodules to. Nested
+// submodules are pinned by those commits and are not listed.
+func SubmodulePins(mirrorDir, rev string) (map[string]string, error) {
+	out, err := output(mirrorDir, "git", "ls-tree", "-r", rev)
+	if err != nil {
+		return nil, err
+	}
+	pins := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if ok && len(fields) == 3 && fields[1] == "commit" {
+			pins[path] = fields[2]
+		}
+	}
+	return pins, nil
+}
@@ -0,0 +1,62 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"argon-go/utils"
+)
+
+type Hg struct{}
+
+func (Hg) Name() string {
+	return "hg"
+}
+
+func (Hg) Fetch(urls []string, cacheDir string) error {
+	return tryURLs(urls, func(url string) error {
+		if utils.DirectoryExists(cacheDir) {
+			fmt.Printf("Fetching updates into %s\n", cacheDir)
+			return run("", "hg", "pull", "-R", cacheDir, url)
+		}
+		if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+			return err
+		}
+		tmpDir := cacheDir + ".tmp"
+		os.RemoveAll(tmpDir)
+		if err := run("", "hg", "clone", "--noupdate", url, tmpDir); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+		return os.Rename(tmpDir, cacheDir)
+	})
+}
+
+func (Hg) Clone(cacheDir, dest, rev string) error {
+	if rev == "" {
+		rev = "default"
+	}
+	if utils.DirectoryExists(dest) && utils.IsDirEmpty(dest) {
+		os.Remove(dest)
+	}
+	return run("", "hg", "clone", "--updaterev", rev, cacheDir, dest)
+}
+
+func (Hg) Revision(dir string) (string, error) {
+	return output(dir, "hg", "log", "--rev", ".", "--template", "{node}")
+}
+
+func (Hg) RemoteRevision(urls []string, cacheDir, ref string) (string, error) {
+	if ref == "" {
+		ref = "default"
+	}
+	var hash string
+	err := tryURLs(urls, func(url string) error {
+		out, err := output("", "hg", "identify", "--debug", "--id", "--rev", ref, url)
+		hash = strings.TrimSuffix(out, "+")
+		return err
+	})
+	return hash, err
+}
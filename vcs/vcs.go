@@ -0,0 +1,193 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"argon-go/utils"
+)
+
+const Default = "git"
+
+type VCS interface {
+	Name() string
+	// Fetch creates or updates the cached copy of the remote at cacheDir.
+	Fetch(urls []string, cacheDir string) error
+	// Clone checks out rev from the cached copy into dest.
+	Clone(cacheDir, dest, rev string) error
+	Revision(dir string) (string, error)
+	RemoteRevision(urls []string, cacheDir, ref string) (string, error)
+}
+
+type TagLister interface {
+	RemoteTags(urls []string) (map[string]string, error)
+}
+
+type Pruner interface {
+	Prune(cacheDir string)
+}
+
+// RefFetcher copies a ref outside branches and tags (like refs/pull/1/head)
+// into the cache so it can be cloned.
+type RefFetcher interface {
+	FetchRef(urls []string, cacheDir, ref string) error
+}
+
+// SparseCloner checks out only the given paths (plus top-level files).
+type SparseCloner interface {
+	CloneSparse(cacheDir, dest, rev string, paths []string) error
+}
+
+var backends = map[string]VCS{
+	"git":    Git{},
+	"hg":     Hg{},
+	"fossil": Fossil{},
+}
+
+func Register(backend VCS) {
+	backends[backend.Name()] = backend
+}
+
+func Get(name string) (VCS, error) {
+	if name == "" {
+		name = Default
+	}
+	backend, ok := backends[name]
+	if !ok {
+		names := make([]string, 0, len(backends))
+		for n := range backends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown vcs %q (want one of %s)", name, strings.Join(names, ", "))
+	}
+	return backend, nil
+}
+
+func ForRemote(remote utils.Remote) (VCS, error) {
+	return Get(remote.VCS)
+}
+
+func Prune(backend VCS, cacheDir string) {
+	if pruner, ok := backend.(Pruner); ok {
+		pruner.Prune(cacheDir)
+	}
+}
+
+// CloneSubdir clones rev, sparsely when the backend supports it and subdir is set.
+func CloneSubdir(backend VCS, cacheDir, dest, rev, subdir string) error {
+	if sparse, ok := backend.(SparseCloner); ok && subdir != "" {
+		err := sparse.CloneSparse(cacheDir, dest, rev, []string{subdir})
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("Sparse checkout failed (%v), checking out everything\n", err)
+		os.RemoveAll(dest)
+		Prune(backend, cacheDir)
+	}
+	return backend.Clone(cacheDir, dest, rev)
+}
+
+func RemoteHash(backend VCS, remote utils.Remote, track utils.Track) (string, string, error) {
+	urls := remote.FetchURLs()
+	cacheDir := utils.SourceCachePath(remote)
+
+	switch track.Kind {
+	case utils.TrackLatestTag:
+		lister, ok := backend.(TagLister)
+		if !ok {
+			return "", "", fmt.Errorf("%s does not support %s tracking", backend.Name(), utils.TrackLatestTag)
+		}
+		hashes, err := lister.RemoteTags(urls)
+		if err != nil {
+			return "", "", err
+		}
+		tags := make([]string, 0, len(hashes))
+		for name := range hashes {
+			tags = append(tags, name)
+		}
+		tag, ok := utils.LatestTag(track, tags)
+		if !ok {
+			return "", "", fmt.Errorf("no tags matching %s found on remote", track)
+		}
+		return hashes[tag], tag, nil
+	case utils.TrackBranch:
+		hash, err := backend.RemoteRevision(urls, cacheDir, track.Branch)
+		return hash, track.Branch, err
+	case utils.TrackRef:
+		if _, ok := backend.(RefFetcher); !ok {
+			return "", "", fmt.Errorf("%s does not support %s tracking", backend.Name(), utils.TrackRef)
+		}
+		hash, err := backend.RemoteRevision(urls, cacheDir, track.Ref)
+		return hash, track.Ref, err
+	}
+	hash, err := backend.RemoteRevision(urls, cacheDir, "")
+	return hash, "", err
+}
+
+// Detect picks the backend for a local working directory.
+func Detect(dir string) VCS {
+	switch {
+	case utils.DirectoryExists(dir + "/.hg"):
+		return Hg{}
+	case utils.FileExists(dir+"/.fslckout") || utils.FileExists(dir+"/_FOSSIL_"):
+		return Fossil{}
+	}
+	return Git{}
+}
+
+// LocalRevision returns the checked out revision of dir and whether it has
+// local changes. A tree whose status can't be read counts as changed.
+func LocalRevision(dir string) (string, bool, error) {
+	backend := Detect(dir)
+	hash, err := backend.Revision(dir)
+	if err != nil {
+		return "", false, err
+	}
+	var cmd *exec.Cmd
+	switch backend.(type) {
+	case Hg:
+		cmd = exec.Command("hg", "status")
+	case Fossil:
+		cmd = exec.Command("fossil", "changes")
+	default:
+		cmd = exec.Command("git", "status", "--porcelain")
+	}
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return hash, true, fmt.Errorf("%s status: %w", backend.Name(), err)
+	}
+	return hash, len(strings.TrimSpace(string(output))) > 0, nil
+}
+
+func tryURLs(urls []string, fn func(url string) error) error {
+	var err error
+	for i, url := range urls {
+		if i > 0 {
+			fmt.Printf("Trying mirror %s\n", url)
+		}
+		if err = fn(url); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func run(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func output(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}